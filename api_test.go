@@ -2,20 +2,29 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"encoding/xml"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/asvins/common_db/postgres"
 	"github.com/asvins/router"
 	"github.com/asvins/utils/config"
+	"github.com/asvins/warehouse/document"
+	"github.com/asvins/warehouse/edi"
+	"github.com/asvins/warehouse/models"
+	"github.com/asvins/warehouse/token"
 	"github.com/jinzhu/gorm"
 )
 
@@ -493,7 +502,8 @@ func TestApproveOrder(t *testing.T) {
 
 	order := getOpenOrder()
 	id := strconv.Itoa(order.ID)
-	response, err := makeRequest(router.PUT, "http://127.0.0.1:8080/api/inventory/order/"+id+"/approve", make([]byte, 1), _headers)
+	approval := []byte(`{"comment": "ok"}`)
+	response, err := makeRequest(router.PUT, "http://127.0.0.1:8080/api/inventory/order/"+id+"/approve", approval, map[string]string{"X-User": "tester", "X-Role": "manager"})
 
 	if err != nil {
 		t.Error(err)
@@ -614,6 +624,15 @@ func TestConfirmePurchase(t *testing.T) {
 		t.Error("[ERROR] /purchase/:id/conclude should have received status 200, Got: ", response.StatusCode)
 	}
 
+	again, err := makeRequest(router.PUT, "http://127.0.0.1:8080/api/inventory/purchase/"+strconv.Itoa(id)+"/confirm", make([]byte, 1), _headers)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if again.StatusCode != http.StatusBadRequest {
+		t.Error("[ERROR] Confirming a confirmed purchase should have received status 400, Got: ", again.StatusCode)
+	}
+
 	openResponse2, err := makeRequest(router.GET, "http://127.0.0.1:8080/api/inventory/purchase/query/open", make([]byte, 1), _headers)
 	if err != nil {
 		t.Error(err)
@@ -703,3 +722,1967 @@ func TestWithdrawalBuildQuery(t *testing.T) {
 
 	fmt.Println("[INFO] -- TestWithdrawalBuildQuery end --\n")
 }
+
+func TestApprovalRulesRequiredRoles(t *testing.T) {
+	fmt.Println("[INFO] -- TestApprovalRulesRequiredRoles start --")
+	rules := models.ApprovalRules{Levels: []models.ApprovalLevel{{Threshold: 1000, Role: models.RoleManager}, {Threshold: 10000, Role: models.RoleFinance}}}
+
+	if roles := rules.RequiredRoles(999.99); len(roles) != 0 {
+		t.Error("[ERROR] Orders below 1000 should be auto-approved, Got: ", roles)
+	}
+
+	if roles := rules.RequiredRoles(5000); len(roles) != 1 || roles[0] != models.RoleManager {
+		t.Error("[ERROR] Orders up to 10000 should require a manager, Got: ", roles)
+	}
+
+	if roles := rules.RequiredRoles(20000); len(roles) != 2 {
+		t.Error("[ERROR] Orders above 10000 should require a manager and finance, Got: ", roles)
+	}
+
+	fmt.Println("[INFO] -- TestApprovalRulesRequiredRoles end --\n")
+}
+
+func TestRejectOrder(t *testing.T) {
+	fmt.Println("[INFO] -- TestRejectOrder start --")
+	now := int(time.Now().Unix())
+	rules := models.ApprovalRules{Levels: []models.ApprovalLevel{{Threshold: 1000, Role: models.RoleManager}}}
+	order := models.Order{CreatedAt: now}
+	testdb.Create(&order)
+	testdb.Create(&models.PurchaseProduct{OrderId: order.ID, Quantity: 10, Value: 2000})
+
+	defer func() {
+		testdb.Where("order_id = ?", order.ID).Delete(models.OrderApproval{})
+		testdb.Where("order_id = ?", order.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+	}()
+
+	if err := order.Reject(testdb, rules, &models.OrderApproval{Approver: "boss", Role: models.RoleManager, Comment: "too early"}); err == nil {
+		t.Error("[ERROR] An order should only be rejected once submitted")
+	}
+
+	testdb.Model(&order).UpdateColumn(models.Order{SubmittedAt: now})
+	if err := order.Reject(testdb, rules, &models.OrderApproval{Approver: "clerk", Role: models.RoleFinance, Comment: "no"}); err == nil {
+		t.Error("[ERROR] A role not required to approve the order shouldn't reject it")
+	}
+
+	if err := order.Reject(testdb, rules, &models.OrderApproval{Approver: "boss", Role: models.RoleManager, Comment: "not needed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := models.Order{}
+	testdb.Where(models.Order{ID: order.ID}).First(&rejected)
+	approvals, _ := (&models.OrderApproval{OrderId: order.ID}).Retreive(testdb)
+	if !rejected.Rejected || rejected.ClosedAt == 0 || len(approvals) != 1 || approvals[0].Approved {
+		t.Error("[ERROR] The order should be closed as rejected along with the decision, Got: ", rejected, approvals)
+	}
+
+	fmt.Println("[INFO] -- TestRejectOrder end --\n")
+}
+
+func TestOrderSchedulerDue(t *testing.T) {
+	fmt.Println("[INFO] -- TestOrderSchedulerDue start --")
+	c := Config{}
+	c.Scheduler.Weekday = "Monday"
+	c.Scheduler.Time = "08:00"
+	c.Scheduler.Interval = 60
+
+	s, err := newOrderScheduler(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2016, time.March, 7, 8, 0, 0, 0, time.Local)
+	s.lastRun = monday.Add(-time.Hour)
+	if s.due(monday.Add(-time.Minute)) {
+		t.Error("[ERROR] Order shouldn't be due before the scheduled time")
+	}
+
+	if !s.due(monday) {
+		t.Error("[ERROR] Order should be due at the scheduled time")
+	}
+
+	s.lastRun = monday
+	if s.due(monday.Add(time.Minute)) {
+		t.Error("[ERROR] Order shouldn't be due twice in the same week")
+	}
+
+	if s.due(monday.AddDate(0, 0, 1)) {
+		t.Error("[ERROR] Order shouldn't be due on another weekday")
+	}
+
+	if !s.due(monday.AddDate(0, 0, 7).Add(time.Hour)) {
+		t.Error("[ERROR] Order should be due again the next week")
+	}
+
+	c.Scheduler.Weekday = "someday"
+	if _, err := newOrderScheduler(&c); err == nil {
+		t.Error("[ERROR] Invalid weekday should be refused")
+	}
+
+	fmt.Println("[INFO] -- TestOrderSchedulerDue end --\n")
+}
+
+func TestNewJobs(t *testing.T) {
+	fmt.Println("[INFO] -- TestNewJobs start --")
+	c := Config{}
+	c.Scheduler.LateInterval = 3600
+	c.Edi.InboundInterval = 0
+
+	jobs, err := newJobs(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jobs) != 1 || jobs[0].name != "late purchases" || jobs[0].interval != time.Hour {
+		t.Error("[ERROR] Only the late purchases job should run, every hour, Got: ", jobs)
+	}
+
+	c.Edi.InboundInterval = -1
+	if _, err := newJobs(&c); err == nil {
+		t.Error("[ERROR] A negative interval should be refused")
+	}
+
+	fmt.Println("[INFO] -- TestNewJobs end --\n")
+}
+
+func TestOrderLines(t *testing.T) {
+	fmt.Println("[INFO] -- TestOrderLines start --")
+	p := models.Product{Name: "order line syringe", CurrQuantity: 10, MinQuantity: 100}
+	if err := p.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		testdb.Where("product_id = ?", p.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&p)
+	}()
+
+	order, err := models.GetOpenOrder(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line := models.PurchaseProduct{ProductId: p.ID, Quantity: 200}
+	if err := order.UpdateLine(testdb, &line); err != nil {
+		t.Fatal(err)
+	}
+
+	p.CurrQuantity = 5
+	if err := p.Update(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	pps, err := (&models.PurchaseProduct{ProductId: p.ID, OrderId: order.ID}).Retreive(testdb)
+	if err != nil || len(pps) != 1 || pps[0].Quantity != 200 || !pps[0].Manual {
+		t.Error("[ERROR] The automatic refill shouldn't overwrite a line edited by a buyer, Got: ", pps, err)
+	}
+
+	if err := order.RemoveLine(testdb, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	p.CurrQuantity = 4
+	if err := p.Update(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	pps, err = (&models.PurchaseProduct{ProductId: p.ID, OrderId: order.ID}).Retreive(testdb)
+	if err != nil || len(pps) != 1 || pps[0].Quantity != 0 {
+		t.Error("[ERROR] A removed line shouldn't be refilled until the order is closed, Got: ", pps, err)
+	}
+
+	fmt.Println("[INFO] -- TestOrderLines end --\n")
+}
+
+func TestRfqAward(t *testing.T) {
+	fmt.Println("[INFO] -- TestRfqAward start --")
+	now := int(time.Now().Unix())
+	validUntil := int(time.Now().AddDate(0, 1, 0).Unix())
+	first := models.Supplier{Name: "rfq supplier one"}
+	second := models.Supplier{Name: "rfq supplier two"}
+	for _, s := range []*models.Supplier{&first, &second} {
+		if err := s.Save(testdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gloves := models.Product{Name: "rfq gloves", CurrQuantity: 10}
+	masks := models.Product{Name: "rfq masks", CurrQuantity: 10}
+	testdb.Create(&gloves)
+	testdb.Create(&masks)
+	order := models.Order{CreatedAt: now}
+	testdb.Create(&order)
+	glovesLine := models.PurchaseProduct{ProductId: gloves.ID, OrderId: order.ID, Quantity: 10}
+	masksLine := models.PurchaseProduct{ProductId: masks.ID, OrderId: order.ID, Quantity: 5}
+	testdb.Create(&glovesLine)
+	testdb.Create(&masksLine)
+
+	orders := []models.Order{order}
+	defer func() {
+		rfqs := []models.Rfq{}
+		testdb.Where(models.Rfq{OrderId: order.ID}).Find(&rfqs)
+		for _, rfq := range rfqs {
+			testdb.Where("rfq_id = ?", rfq.ID).Delete(models.Quote{})
+			testdb.Delete(&rfq)
+		}
+		for _, o := range orders {
+			testdb.Where("order_id = ?", o.ID).Delete(models.Purchase{})
+			testdb.Where("order_id = ?", o.ID).Delete(models.OrderApproval{})
+			testdb.Where("order_id = ?", o.ID).Delete(models.PurchaseProduct{})
+			testdb.Where("id = ?", o.ID).Delete(models.Order{})
+		}
+		testdb.Delete(&gloves)
+		testdb.Delete(&masks)
+		testdb.Delete(&first)
+		testdb.Delete(&second)
+	}()
+
+	rfqs, err := models.NewRfqsFromOrder(testdb, &order, []int{first.ID, second.ID})
+	if err != nil || len(rfqs) != 2 || len(rfqs[0].Lines) != 2 {
+		t.Fatal("[ERROR] One rfq with every line should be sent to each supplier, Got: ", rfqs, err)
+	}
+
+	if err := rfqs[0].SubmitQuotes(testdb, []models.Quote{
+		{PurchaseProductId: glovesLine.ID, Price: 2, LeadTimeDays: 5, ValidUntil: validUntil},
+		{PurchaseProductId: masksLine.ID, Price: 9, LeadTimeDays: 5, ValidUntil: validUntil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rfqs[1].SubmitQuotes(testdb, []models.Quote{
+		{PurchaseProductId: glovesLine.ID, Price: 3, LeadTimeDays: 2, ValidUntil: validUntil},
+		{PurchaseProductId: masksLine.ID, Price: 7, LeadTimeDays: 2, ValidUntil: validUntil},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	comparison, err := models.CompareQuotes(testdb, &order)
+	if err != nil || len(comparison) != 2 {
+		t.Fatal("[ERROR] Every line should be compared, Got: ", comparison, err)
+	}
+
+	awards := []models.Award{}
+	for _, lq := range comparison {
+		if len(lq.Quotes) != 2 || lq.Quotes[0].Price > lq.Quotes[1].Price {
+			t.Fatal("[ERROR] Quotes should be listed cheapest first, Got: ", lq.Quotes)
+		}
+		awards = append(awards, models.Award{PurchaseProductId: lq.Line.ID, QuoteId: lq.Quotes[0].ID})
+	}
+
+	rules := models.ApprovalRules{Budget: models.BudgetPolicy{OverBudget: models.OverBudgetEscalate, EscalationRole: "controller"}}
+	invalid := []models.Award{awards[0], {PurchaseProductId: awards[1].PurchaseProductId, QuoteId: awards[0].QuoteId}}
+	if _, err := order.Award(testdb, rules, invalid); err == nil {
+		t.Fatal("[ERROR] A quote of another line shouldn't be awarded")
+	}
+
+	quote := models.Quote{}
+	line := models.PurchaseProduct{}
+	testdb.Where(models.Quote{ID: awards[0].QuoteId}).First(&quote)
+	testdb.Where(models.PurchaseProduct{ID: awards[0].PurchaseProductId}).First(&line)
+	if quote.Awarded || line.SupplierId != 0 || line.Value != 0 {
+		t.Error("[ERROR] An invalid award shouldn't award any line, Got: ", quote, line)
+	}
+
+	awarded, err := order.Award(testdb, rules, awards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders = append(orders, awarded...)
+
+	if len(awarded) != 2 {
+		t.Fatal("[ERROR] The order should be split per winning supplier, Got: ", awarded)
+	}
+
+	for _, o := range awarded {
+		if len(o.Pproducts) != 1 {
+			t.Error("[ERROR] Each supplier should get the line it won, Got: ", o.Pproducts)
+			continue
+		}
+
+		line := o.Pproducts[0]
+		if line.ProductId == gloves.ID && (o.SupplierId != first.ID || line.Value != 20) {
+			t.Error("[ERROR] Gloves should be awarded to the first supplier at 2 each, Got: ", o.SupplierId, line.Value)
+		}
+
+		if line.ProductId == masks.ID && (o.SupplierId != second.ID || line.Value != 35) {
+			t.Error("[ERROR] Masks should be awarded to the second supplier at 7 each, Got: ", o.SupplierId, line.Value)
+		}
+	}
+
+	fmt.Println("[INFO] -- TestRfqAward end --\n")
+}
+
+func TestMatchToleranceDiscrepancy(t *testing.T) {
+	fmt.Println("[INFO] -- TestMatchToleranceDiscrepancy start --")
+	tol := models.MatchTolerance{Quantity: 0, Price: 0.02}
+	pp := models.PurchaseProduct{Quantity: 10, ReceivedQuantity: 8, Value: 100}
+
+	if d := tol.Discrepancy(models.InvoiceLine{Quantity: 8, UnitPrice: 10.1}, pp, 0); d != "" {
+		t.Error("[ERROR] Invoice line within the tolerance should match, Got: ", d)
+	}
+
+	if d := tol.Discrepancy(models.InvoiceLine{Quantity: 10, UnitPrice: 10}, pp, 0); d == "" {
+		t.Error("[ERROR] Invoiced quantity above the received quantity should be flagged")
+	}
+
+	if d := tol.Discrepancy(models.InvoiceLine{Quantity: 8, UnitPrice: 11}, pp, 0); d == "" {
+		t.Error("[ERROR] Invoiced price out of the tolerance should be flagged")
+	}
+
+	if d := tol.Discrepancy(models.InvoiceLine{Quantity: 4, UnitPrice: 10}, pp, 5); d == "" {
+		t.Error("[ERROR] Quantity already invoiced should be counted, Got no discrepancy")
+	}
+
+	pp.ReturnedQuantity = 3
+	if d := tol.Discrepancy(models.InvoiceLine{Quantity: 8, UnitPrice: 10}, pp, 0); d == "" {
+		t.Error("[ERROR] Quantity returned to the supplier shouldn't be billable")
+	}
+
+	fmt.Println("[INFO] -- TestMatchToleranceDiscrepancy end --\n")
+}
+
+func TestInvoiceAndReceiptLimits(t *testing.T) {
+	fmt.Println("[INFO] -- TestInvoiceAndReceiptLimits start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "limits syringe", MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, Value: 100}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 100}
+	testdb.Create(&purchase)
+
+	receipt := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{{PurchaseProductId: pp.ID, Quantity: 11}}}
+	defer func() {
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Where("purchase_id = ?", purchase.ID).Delete(models.Invoice{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	invalid := [][]models.InvoiceLine{
+		{{PurchaseProductId: pp.ID, Quantity: 0, UnitPrice: 10}},
+		{{PurchaseProductId: pp.ID, Quantity: 10, UnitPrice: -10}},
+		{{PurchaseProductId: pp.ID + 1000000, Quantity: 10, UnitPrice: 10}},
+	}
+	for _, lines := range invalid {
+		inv := models.Invoice{PurchaseId: purchase.ID, Number: "NF-LIMIT", Lines: lines}
+		if err := inv.Save(testdb); err == nil {
+			t.Error("[ERROR] Invoice line should have been refused, Got: ", lines)
+		}
+	}
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{}); err == nil {
+		t.Error("[ERROR] Receiving more than ordered should be refused")
+	}
+
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{QuantityTolerance: 0.1}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := models.PurchaseProduct{}
+	testdb.Where(models.PurchaseProduct{ID: pp.ID}).First(&received)
+	if received.ReceivedQuantity != 11 {
+		t.Error("[ERROR] Receiving within the tolerance should be accepted, Got: ", received.ReceivedQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestInvoiceAndReceiptLimits end --\n")
+}
+
+func TestSupplierReturnCredit(t *testing.T) {
+	fmt.Println("[INFO] -- TestSupplierReturnCredit start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "return catheter", CurrQuantity: 10, MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, ReceivedQuantity: 10, Value: 100}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 100}
+	testdb.Create(&purchase)
+
+	sr := models.SupplierReturn{PurchaseId: purchase.ID, Reason: "wrong size", Lines: []models.SupplierReturnLine{{PurchaseProductId: pp.ID, Quantity: 3}}}
+	defer func() {
+		testdb.Where("supplier_return_id = ?", sr.ID).Delete(models.CreditNote{})
+		testdb.Where("supplier_return_id = ?", sr.ID).Delete(models.SupplierReturnLine{})
+		testdb.Where("id = ?", sr.ID).Delete(models.SupplierReturn{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	if err := sr.Save(testdb, models.Signoff{}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 7 {
+		t.Error("[ERROR] Returned goods should leave the stock, Got: ", p.CurrQuantity)
+	}
+
+	tooMuch := models.SupplierReturn{PurchaseId: purchase.ID, Reason: "wrong size", Lines: []models.SupplierReturnLine{{PurchaseProductId: pp.ID, Quantity: 8}}}
+	if err := tooMuch.Save(testdb, models.Signoff{}); err == nil {
+		t.Error("[ERROR] More than the received quantity shouldn't be returned")
+	}
+
+	if err := sr.Credit(testdb, &models.CreditNote{Number: "CN-1", Amount: 30}); err == nil {
+		t.Error("[ERROR] A return should only be credited once shipped")
+	}
+
+	if err := sr.Ship(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sr.Credit(testdb, &models.CreditNote{Number: "CN-1", Amount: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	purchases, err := (&models.Purchase{ID: purchase.ID}).Retreive(testdb)
+	if err != nil || len(purchases) != 1 || purchases[0].PayableValue != 70 || sr.Status != models.ReturnCredited {
+		t.Error("[ERROR] The credit note should reduce the payable amount, Got: ", purchases, err)
+	}
+
+	fmt.Println("[INFO] -- TestSupplierReturnCredit end --\n")
+}
+
+func TestPurchaseExpectedDelivery(t *testing.T) {
+	fmt.Println("[INFO] -- TestPurchaseExpectedDelivery start --")
+	now := int(time.Now().Unix())
+	supplier := models.Supplier{Name: "lead time supplier", LeadTimeDays: 5}
+	if err := supplier.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now, SupplierId: supplier.ID}
+	testdb.Create(&order)
+	purchase := models.Purchase{OrderId: order.ID, SupplierId: supplier.ID, CreatedAt: now}
+	testdb.Create(&purchase)
+
+	defer func() {
+		testdb.Delete(&purchase)
+		testdb.Delete(&order)
+		testdb.Delete(&supplier)
+	}()
+
+	if err := purchase.Confirm(testdb, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if purchase.ExpectedAt != purchase.ConfirmedAt+5*24*60*60 {
+		t.Error("[ERROR] Delivery should be expected after the supplier's lead time, Got: ", purchase.ExpectedAt)
+	}
+
+	if err := purchase.Confirm(testdb, 0); err == nil {
+		t.Error("[ERROR] A confirmed purchase shouldn't be confirmed again")
+	}
+
+	isLate := func() bool {
+		late, err := (&models.Purchase{}).RetreiveLate(testdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range late {
+			if p.ID == purchase.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if isLate() {
+		t.Error("[ERROR] A purchase within its delivery date shouldn't be late")
+	}
+
+	testdb.Model(&purchase).UpdateColumn(models.Purchase{ExpectedAt: now - 60})
+	if !isLate() {
+		t.Error("[ERROR] A purchase past its delivery date should be late")
+	}
+
+	testdb.Model(&purchase).UpdateColumn(models.Purchase{RejectedAt: now})
+	if isLate() {
+		t.Error("[ERROR] A rejected purchase shouldn't be late")
+	}
+
+	fmt.Println("[INFO] -- TestPurchaseExpectedDelivery end --\n")
+}
+
+func TestSupplierScorecards(t *testing.T) {
+	fmt.Println("[INFO] -- TestSupplierScorecards start --")
+	day := 24 * 60 * 60
+	confirmedAt := int(time.Now().Unix()) - 10*day
+	supplier := models.Supplier{Name: "scorecard supplier"}
+	if err := supplier.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{Name: "scorecard bandage", CurrQuantity: 10, CurrentValue: 1}
+	testdb.Create(&product)
+
+	// delivered in full and on time, 10% above the product's value
+	// then half delivered and late, 10% below it
+	deliveries := []struct {
+		leadTime, received int
+		value              float64
+	}{{2, 10, 11}, {4, 5, 9}}
+
+	orders := []models.Order{}
+	purchases := []models.Purchase{}
+	defer func() {
+		for _, p := range purchases {
+			testdb.Delete(&p)
+		}
+		for _, o := range orders {
+			testdb.Where("order_id = ?", o.ID).Delete(models.PurchaseProduct{})
+			testdb.Delete(&o)
+		}
+		testdb.Delete(&product)
+		testdb.Delete(&supplier)
+	}()
+
+	for _, d := range deliveries {
+		order := models.Order{Approved: true, CreatedAt: confirmedAt, SubmittedAt: confirmedAt, ClosedAt: confirmedAt, SupplierId: supplier.ID}
+		testdb.Create(&order)
+		orders = append(orders, order)
+		testdb.Create(&models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, ReceivedQuantity: d.received, Value: d.value, SupplierId: supplier.ID})
+
+		purchase := models.Purchase{
+			OrderId:     order.ID,
+			SupplierId:  supplier.ID,
+			CreatedAt:   confirmedAt,
+			ConfirmedAt: confirmedAt,
+			ExpectedAt:  confirmedAt + 3*day,
+			ConcludedAt: confirmedAt + d.leadTime*day,
+			TotalValue:  d.value,
+		}
+		testdb.Create(&purchase)
+		purchases = append(purchases, purchase)
+	}
+
+	scorecards, err := models.SupplierScorecards(testdb, confirmedAt, confirmedAt+5*day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sc *models.SupplierScorecard
+	for i := range scorecards {
+		if scorecards[i].SupplierId == supplier.ID {
+			sc = &scorecards[i]
+		}
+	}
+
+	if sc == nil || sc.Purchases != 2 {
+		t.Fatal("[ERROR] Scorecard should cover both purchases, Got: ", sc)
+	}
+
+	if sc.AverageLeadTimeDays != 3 || sc.LeadTimeVarianceDays != 1 || sc.OnTimeRate != 0.5 || sc.FillRate != 0.75 {
+		t.Error("[ERROR] Delivery KPIs should be 3 days average, 1 of variance, 50% on time and 75% filled, Got: ", *sc)
+	}
+
+	if sc.PriceVariance > 1e-9 || sc.PriceVariance < -1e-9 {
+		t.Error("[ERROR] Prices 10% above and below the product's value should average no variance, Got: ", sc.PriceVariance)
+	}
+
+	fmt.Println("[INFO] -- TestSupplierScorecards end --\n")
+}
+
+func TestSupplierToken(t *testing.T) {
+	fmt.Println("[INFO] -- TestSupplierToken start --")
+	valid := token.Sign("secret", 42, time.Now().Unix()+60)
+
+	if id, err := token.Verify("secret", valid); err != nil || id != 42 {
+		t.Error("[ERROR] Valid token should grant access to purchase 42, Got: ", id, err)
+	}
+
+	if _, err := token.Verify("other-secret", valid); err == nil {
+		t.Error("[ERROR] Token signed with another secret should be refused")
+	}
+
+	if _, err := token.Verify("secret", strings.Replace(valid, "42.", "43.", 1)); err == nil {
+		t.Error("[ERROR] Tampered token should be refused")
+	}
+
+	if _, err := token.Verify("secret", token.Sign("secret", 42, time.Now().Unix()-1)); err == nil {
+		t.Error("[ERROR] Expired token should be refused")
+	}
+
+	if subject, err := token.VerifySubject("secret", token.SignSubject("secret", "dr.house", time.Now().Unix()+60)); err != nil || subject != "dr.house" {
+		t.Error("[ERROR] Token should vouch for a subject with dots, Got: ", subject, err)
+	}
+
+	fmt.Println("[INFO] -- TestSupplierToken end --\n")
+}
+
+func TestLoadSecrets(t *testing.T) {
+	fmt.Println("[INFO] -- TestLoadSecrets start --")
+	c := Config{}
+	c.SupplierPortal.Secret = "dev-supplier-portal-secret"
+	c.Register.Secret = "dev-register-secret"
+
+	os.Setenv("WAREHOUSE_SUPPLIER_PORTAL_SECRET", "from-environment")
+	defer os.Unsetenv("WAREHOUSE_SUPPLIER_PORTAL_SECRET")
+	c.LoadSecrets()
+
+	if c.SupplierPortal.Secret != "from-environment" {
+		t.Error("[ERROR] The secret in the environment should replace the configured one, Got: ", c.SupplierPortal.Secret)
+	}
+
+	if c.Register.Secret != "dev-register-secret" {
+		t.Error("[ERROR] Secrets not set in the environment should be kept, Got: ", c.Register.Secret)
+	}
+
+	if err := c.ValidateSupplierPortal(); err != nil {
+		t.Error(err)
+	}
+
+	c.SupplierPortal.Secret = "change-me"
+	if err := c.ValidateSupplierPortal(); err == nil {
+		t.Error("[ERROR] The placeholder secret should be refused")
+	}
+
+	fmt.Println("[INFO] -- TestLoadSecrets end --\n")
+}
+
+func TestPurchaseOrderDocument(t *testing.T) {
+	fmt.Println("[INFO] -- TestPurchaseOrderDocument start --")
+	po := document.PurchaseOrder{Company: document.Company{Name: "Asvins"}, Supplier: document.Party{Name: "Pharma"}, Number: 7, Total: 180}
+	name := strings.TrimSpace(strings.Repeat("paracetamol 500mg ", 6))
+	for i := 0; i < 60; i++ {
+		po.Lines = append(po.Lines, document.PurchaseOrderLine{ProductName: name, Quantity: 2, Unit: "box", UnitPrice: 1.5, Total: 3})
+	}
+
+	out := &bytes.Buffer{}
+	if err := po.WriteCSV(out); err != nil {
+		t.Error(err)
+	}
+
+	reader := csv.NewReader(out)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(records) != 68 || strings.Join(records[7], ";") != name+";2;box;1.50;3.00" || strings.Join(records[67], ";") != "total;;;;180.00" {
+		t.Error("[ERROR] CSV should have the header fields, the 60 lines and the total, Got: ", records)
+	}
+
+	out.Reset()
+	if err := po.WritePDF(out); err != nil {
+		t.Error(err)
+	}
+
+	pdf := out.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Error("[ERROR] PDF should start with its version and end with the EOF marker")
+	}
+
+	pages := strings.Count(pdf, "/Type /Page /Parent")
+	if pages < 2 {
+		t.Error("[ERROR] 60 lines with long names should take more than one page, Got: ", pages)
+	}
+
+	if headers := strings.Count(pdf, "(Product) Tj"); headers != pages {
+		t.Error("[ERROR] Lines header should be repeated on each of the ", pages, " pages, Got: ", headers)
+	}
+
+	for _, cell := range regexp.MustCompile(`\((paracetamol[^)]*)\) Tj`).FindAllStringSubmatch(pdf, -1) {
+		if len(cell[1]) > 40 {
+			t.Error("[ERROR] Product names should be wrapped before the quantity column, Got: ", cell[1])
+		}
+	}
+
+	fmt.Println("[INFO] -- TestPurchaseOrderDocument end --\n")
+}
+
+func TestEdiFileDrop(t *testing.T) {
+	fmt.Println("[INFO] -- TestEdiFileDrop start --")
+	dir, err := ioutil.TempDir("", "edi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	confirmation := `<?xml version="1.0" encoding="UTF-8"?>
+<cXML payloadID="1@supplier" timestamp="2026-01-01T00:00:00Z">
+  <Request>
+    <ConfirmationRequest>
+      <ConfirmationHeader type="detail" noticeDate="2026-01-01T00:00:00Z"/>
+      <OrderReference orderID="3"/>
+      <ConfirmationItem lineNumber="5" quantity="10">
+        <ConfirmationStatus type="detail" quantity="8" deliveryDate="2026-01-10T00:00:00Z"/>
+      </ConfirmationItem>
+    </ConfirmationRequest>
+  </Request>
+</cXML>`
+
+	outbound := &edi.DirOutbound{Dir: dir}
+	if err := outbound.Send("confirmation-3.xml", []byte(confirmation)); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []*edi.Message{}
+	err = edi.ScanInbound(dir, func(data []byte) error {
+		msg, err := edi.Unmarshal(data)
+		if err == nil {
+			messages = append(messages, msg)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 1 || messages[0].PurchaseId != 3 || messages[0].Status != edi.ConfirmDetail {
+		t.Fatal("[ERROR] Confirmation should have been read from the inbound directory, Got: ", messages)
+	}
+
+	if len(messages[0].Lines) != 1 || messages[0].Lines[0].LineNumber != 5 || messages[0].Lines[0].Quantity != 8 {
+		t.Error("[ERROR] Confirmed line should be 5 with quantity 8, Got: ", messages[0].Lines)
+	}
+
+	if _, err := os.Stat(dir + "/processed/confirmation-3.xml"); err != nil {
+		t.Error("[ERROR] Confirmation should have been moved to the processed directory")
+	}
+
+	fmt.Println("[INFO] -- TestEdiFileDrop end --\n")
+}
+
+func TestValidatePurchaseEdi(t *testing.T) {
+	fmt.Println("[INFO] -- TestValidatePurchaseEdi start --")
+	purchase := models.Purchase{ID: 1}
+	if err := validatePurchaseEdi(&purchase); err == nil {
+		t.Error("[ERROR] A purchase of an order not approved shouldn't be sent")
+	}
+
+	purchase.PurschaseOrder.Approved = true
+	if err := validatePurchaseEdi(&purchase); err != nil {
+		t.Error(err)
+	}
+
+	purchase.RejectedAt = int(time.Now().Unix())
+	if err := validatePurchaseEdi(&purchase); err == nil {
+		t.Error("[ERROR] A purchase rejected by the supplier shouldn't be sent")
+	}
+
+	fmt.Println("[INFO] -- TestValidatePurchaseEdi end --\n")
+}
+
+func TestEdiDespatchAdvice(t *testing.T) {
+	fmt.Println("[INFO] -- TestEdiDespatchAdvice start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "edi gauze", CurrQuantity: 10}
+	testdb.Create(&product)
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	lines := []models.PurchaseProduct{
+		{ProductId: product.ID, OrderId: order.ID, Quantity: 5, Value: 50, Unit: "box"},
+		{ProductId: product.ID, OrderId: order.ID, Quantity: 2, Value: 20, Unit: "box"},
+	}
+	for i := range lines {
+		testdb.Create(&lines[i])
+	}
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 70}
+	testdb.Create(&purchase)
+
+	defer func() {
+		testdb.Delete(&purchase)
+		testdb.Where("order_id = ?", order.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	dir, err := ioutil.TempDir("", "edi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ediConfig := ServerConfig.Edi
+	ServerConfig.Edi.Endpoint = ""
+	ServerConfig.Edi.OutboundDir = dir
+	defer func() { ServerConfig.Edi = ediConfig }()
+
+	purchases, err := (&models.Purchase{ID: purchase.ID}).Retreive(testdb)
+	if err != nil || len(purchases) != 1 {
+		t.Fatal("[ERROR] Purchase should be found, Got: ", purchases, err)
+	}
+
+	if err := sendPurchaseEdi(&purchases[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := ioutil.ReadFile(filepath.Join(dir, "order-"+strconv.Itoa(purchase.ID)+".xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orderRequest := struct {
+		Request struct {
+			OrderRequest struct {
+				Header struct {
+					OrderID string `xml:"orderID,attr"`
+				} `xml:"OrderRequestHeader"`
+				Items []struct {
+					LineNumber int `xml:"lineNumber,attr"`
+					Quantity   int `xml:"quantity,attr"`
+				} `xml:"ItemOut"`
+			} `xml:"OrderRequest"`
+		} `xml:"Request"`
+	}{}
+	if err := xml.Unmarshal(sent, &orderRequest); err != nil {
+		t.Fatal(err)
+	}
+
+	items := orderRequest.Request.OrderRequest.Items
+	if len(items) != 2 || items[0].LineNumber != lines[0].ID || items[0].Quantity != 5 {
+		t.Fatal("[ERROR] OrderRequest should have one item per purchase product, Got: ", items)
+	}
+
+	deliveryAt := time.Unix(int64(now+3*24*60*60), 0)
+	advice := `<?xml version="1.0" encoding="UTF-8"?>
+<cXML payloadID="1@supplier" timestamp="` + time.Now().Format(time.RFC3339) + `">
+  <Request>
+    <ShipNoticeRequest>
+      <ShipNoticeHeader shipmentID="SHIP-1" noticeDate="` + time.Now().Format(time.RFC3339) + `" deliveryDate="` + deliveryAt.Format(time.RFC3339) + `"></ShipNoticeHeader>
+      <ShipNoticePortion>
+        <OrderReference orderID="` + orderRequest.Request.OrderRequest.Header.OrderID + `"></OrderReference>
+        <ShipNoticeItem lineNumber="` + strconv.Itoa(items[0].LineNumber) + `" quantity="3"></ShipNoticeItem>
+      </ShipNoticePortion>
+    </ShipNoticeRequest>
+  </Request>
+</cXML>`
+
+	msg, err := edi.Unmarshal([]byte(advice))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyEdiMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	purchases, err = (&models.Purchase{ID: purchase.ID}).Retreive(testdb)
+	if err != nil || len(purchases) != 1 {
+		t.Fatal("[ERROR] Purchase should be found, Got: ", purchases, err)
+	}
+
+	if purchases[0].ExpectedAt != int(deliveryAt.Unix()) {
+		t.Error("[ERROR] Delivery date of the advice should replace the expected one, Got: ", purchases[0].ExpectedAt)
+	}
+
+	for _, pp := range purchases[0].PurschaseOrder.Pproducts {
+		if pp.ID == lines[0].ID && pp.DespatchedQuantity != 3 {
+			t.Error("[ERROR] Despatched line should have 3 despatched, Got: ", pp.DespatchedQuantity)
+		}
+
+		if pp.ID == lines[1].ID && pp.DespatchedQuantity != 0 {
+			t.Error("[ERROR] Line missing from the advice shouldn't be despatched, Got: ", pp.DespatchedQuantity)
+		}
+	}
+
+	fmt.Println("[INFO] -- TestEdiDespatchAdvice end --\n")
+}
+
+func TestApprovalRulesEscalationRole(t *testing.T) {
+	fmt.Println("[INFO] -- TestApprovalRulesEscalationRole start --")
+	c := Config{}
+	c.Approval.Level = []string{"1000 manager", "10000 finance"}
+	c.Approval.OverBudget = models.OverBudgetEscalate
+	c.Approval.EscalationRole = models.RoleFinance
+
+	if _, err := c.ApprovalRules(); err == nil {
+		t.Error("[ERROR] Escalation role already required by a level should be refused")
+	}
+
+	c.Approval.EscalationRole = "controller"
+	if rules, err := c.ApprovalRules(); err != nil || rules.Budget.EscalationRole != "controller" {
+		t.Error("[ERROR] Escalation role outside the levels should be accepted, Got: ", rules, err)
+	}
+
+	fmt.Println("[INFO] -- TestApprovalRulesEscalationRole end --\n")
+}
+
+func TestBudgetIgnoresRejectedPurchases(t *testing.T) {
+	fmt.Println("[INFO] -- TestBudgetIgnoresRejectedPurchases start --")
+	now := int(time.Now().Unix())
+	budget := models.Budget{Period: time.Now().Format("2006-01"), CostCenter: "budget-test", Amount: 1000}
+	if err := budget.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := models.Order{Approved: true, CostCenter: "budget-test", CreatedAt: now, ClosedAt: now}
+	kept := models.Order{Approved: true, CostCenter: "budget-test", CreatedAt: now, ClosedAt: now}
+	testdb.Create(&rejected)
+	testdb.Create(&kept)
+	testdb.Create(&models.PurchaseProduct{OrderId: rejected.ID, Quantity: 3, Value: 300})
+	testdb.Create(&models.PurchaseProduct{OrderId: kept.ID, Quantity: 2, Value: 200})
+	purchases := []models.Purchase{
+		{OrderId: rejected.ID, CreatedAt: now, RejectedAt: now, TotalValue: 300},
+		{OrderId: kept.ID, CreatedAt: now, TotalValue: 200},
+	}
+	for i := range purchases {
+		testdb.Create(&purchases[i])
+	}
+
+	defer func() {
+		for _, p := range purchases {
+			testdb.Delete(&p)
+		}
+		testdb.Where("order_id in (?)", []int{rejected.ID, kept.ID}).Delete(models.PurchaseProduct{})
+		testdb.Delete(&rejected)
+		testdb.Delete(&kept)
+		testdb.Delete(&budget)
+	}()
+
+	budgets, err := (&models.Budget{ID: budget.ID}).Retreive(testdb)
+	if err != nil || len(budgets) != 1 {
+		t.Fatal("[ERROR] Budget should be found, Got: ", budgets, err)
+	}
+
+	if budgets[0].Committed != 200 || budgets[0].Available != 800 {
+		t.Error("[ERROR] Only the purchase not rejected should be committed, Got: ", budgets[0].Committed, budgets[0].Available)
+	}
+
+	fmt.Println("[INFO] -- TestBudgetIgnoresRejectedPurchases end --\n")
+}
+
+func TestRequisitionPick(t *testing.T) {
+	fmt.Println("[INFO] -- TestRequisitionPick start --")
+	product := models.Product{Name: "requisition saline", CurrQuantity: 3, MinQuantity: 100}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	req := models.Requisition{Department: "ward", Requester: "nurse", Lines: []models.RequisitionLine{{ProductId: product.ID, RequestedQuantity: 5}}}
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.AllocationLog{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.Withdrawal{})
+		testdb.Where("requisition_id = ?", req.ID).Delete(models.RequisitionLine{})
+		testdb.Where("id = ?", req.ID).Delete(models.Requisition{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&product)
+	}()
+
+	if err := req.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if _, err := req.Pick(testdb, policy, models.Signoff{}); err == nil {
+		t.Error("[ERROR] A requisition should only be picked once approved")
+	}
+
+	adjustments := []models.RequisitionLine{{ID: req.Lines[0].ID, ApprovedQuantity: 4}}
+	if err := req.Approve(testdb, "pharmacist", adjustments); err != nil {
+		t.Fatal(err)
+	}
+
+	ws, err := req.Pick(testdb, policy, models.Signoff{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ws) != 1 || ws[0].Quantity != 3 || ws[0].RequisitionId != req.ID || ws[0].Department != "ward" {
+		t.Error("[ERROR] The available stock should be picked for the requisition, Got: ", ws)
+	}
+
+	if req.Status != models.RequisitionBackordered || req.Lines[0].PickedQuantity != 3 {
+		t.Error("[ERROR] A partially picked requisition should stay open as a backorder, Got: ", req.Status, req.Lines)
+	}
+
+	testdb.Model(&models.Product{ID: product.ID}).UpdateColumn("curr_quantity", 5)
+	ws, err = req.Pick(testdb, policy, models.Signoff{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ws) != 1 || ws[0].Quantity != 1 || req.Status != models.RequisitionFulfilled {
+		t.Error("[ERROR] Picking again should only take the approved quantity left, Got: ", ws, req.Status)
+	}
+
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 4 {
+		t.Error("[ERROR] Stock should be reduced by the picked quantity, Got: ", p.CurrQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestRequisitionPick end --\n")
+}
+
+func TestBackorderReceipt(t *testing.T) {
+	fmt.Println("[INFO] -- TestBackorderReceipt start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "backorder gloves", CurrQuantity: 2, MinQuantity: 100}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, Value: 10}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 10}
+	testdb.Create(&purchase)
+
+	backorder := models.Backorder{Requester: "nurse", Department: "er"}
+	receipt := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{{PurchaseProductId: pp.ID, Quantity: 10}}}
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.AllocationLog{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.Withdrawal{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.Backorder{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	if err := (&models.Product{ID: product.ID}).ConsumeOrBackorder(testdb, 5, &backorder); err != nil {
+		t.Fatal(err)
+	}
+
+	if backorder.ID == 0 || backorder.Quantity != 3 || backorder.Status != models.BackorderOpen {
+		t.Fatal("[ERROR] The quantity missing should be backordered, Got: ", backorder)
+	}
+
+	if err := receipt.Save(testdb, models.AllocationPolicy{Mode: models.AllocationPriority}, models.ReceivingPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(receipt.Backorders) != 1 || receipt.Backorders[0].ID != backorder.ID || receipt.Backorders[0].Status != models.BackorderFulfilled {
+		t.Error("[ERROR] Receiving the product should fulfill its backorder, Got: ", receipt.Backorders)
+	}
+
+	ws := []models.Withdrawal{}
+	testdb.Where(models.Withdrawal{BackorderId: backorder.ID}).Find(&ws)
+	if len(ws) != 1 || ws[0].Quantity != 3 || ws[0].Requester != "nurse" {
+		t.Error("[ERROR] The backorder should be withdrawn for its requester, Got: ", ws)
+	}
+
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 7 {
+		t.Error("[ERROR] Stock should be the received quantity less the backorder, Got: ", p.CurrQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestBackorderReceipt end --\n")
+}
+
+func TestAllocationPolicyAllocate(t *testing.T) {
+	fmt.Println("[INFO] -- TestAllocationPolicyAllocate start --")
+	now := int(time.Now().Unix())
+	requests := []models.AllocationRequest{
+		{Department: "ward", Criticality: 1, CreatedAt: now, Quantity: 8},
+		{Department: "icu", Criticality: 1, CreatedAt: now, Quantity: 3},
+		{Department: "ward", Criticality: 0, CreatedAt: now, Quantity: 8},
+	}
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority, CriticalityWeight: 10, Departments: map[string]float64{"icu": 30}}
+
+	if as := policy.Allocate(10, requests, now); as[1].Allocated != 3 || as[0].Allocated != 7 || as[2].Allocated != 0 {
+		t.Error("[ERROR] Priority mode should serve the highest scores first, Got: ", as)
+	}
+
+	policy.Mode = models.AllocationFairShare
+	if as := policy.Allocate(10, requests, now); as[0].Allocated != 4 || as[1].Allocated != 3 || as[2].Allocated != 3 {
+		t.Error("[ERROR] Fair share mode should ration the stock equally, Got: ", as)
+	}
+
+	fmt.Println("[INFO] -- TestAllocationPolicyAllocate end --\n")
+}
+
+func TestKitComponents(t *testing.T) {
+	fmt.Println("[INFO] -- TestKitComponents start --")
+	gauze := models.Product{Name: "kit gauze", CurrQuantity: 20}
+	if err := gauze.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	kit := models.Product{Name: "dressing kit", IsKit: true, MinQuantity: 100, Components: []models.KitComponent{{ComponentId: gauze.ID, Quantity: 2}}}
+	if err := kit.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		testdb.Where("product_id in (?)", []int{gauze.ID, kit.ID}).Delete(models.PurchaseProduct{})
+		testdb.Where("product_id = ?", kit.ID).Delete(models.KitComponent{})
+		testdb.Delete(&kit)
+		testdb.Delete(&gauze)
+	}()
+
+	if kit.CurrQuantity != 10 {
+		t.Error("[ERROR] 20 gauzes should make 10 kits, Got: ", kit.CurrQuantity)
+	}
+
+	if err := (&models.Product{ID: gauze.ID}).Delete(testdb); err == nil {
+		t.Error("[ERROR] A kit component shouldn't be deleted")
+	}
+
+	kit.IsKit = false
+	kit.Components = nil
+	if err := kit.Update(testdb); err != nil {
+		t.Error(err)
+	}
+
+	components := []models.KitComponent{}
+	testdb.Where(models.KitComponent{ProductId: kit.ID}).Find(&components)
+	if len(components) != 0 {
+		t.Error("[ERROR] A product that stops being a kit should lose its components, Got: ", components)
+	}
+
+	if err := (&models.Product{ID: gauze.ID}).Delete(testdb); err != nil {
+		t.Error("[ERROR] A product no longer used by a kit should be deleted, Got: ", err)
+	}
+
+	fmt.Println("[INFO] -- TestKitComponents end --\n")
+}
+
+func TestWorkOrderOutputLots(t *testing.T) {
+	fmt.Println("[INFO] -- TestWorkOrderOutputLots start --")
+	expiresAt := int(time.Now().AddDate(1, 0, 0).Unix())
+	bulk := models.Product{Name: "bulk syrup", CurrQuantity: 100, MinQuantity: 1000}
+	dose := models.Product{Name: "syrup dose", MinQuantity: 1000}
+	for _, p := range []*models.Product{&bulk, &dose} {
+		if err := p.Save(testdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	input := models.StockLot{ProductId: bulk.ID, LotNumber: "SY-1", ExpiresAt: expiresAt, Quantity: 100, Status: models.LotAvailable}
+	testdb.Create(&input)
+
+	wo := models.WorkOrder{
+		Inputs:  []models.WorkOrderInput{{ProductId: bulk.ID, Quantity: 10}},
+		Outputs: []models.WorkOrderOutput{{ProductId: dose.ID, ExpectedQuantity: 20}},
+	}
+	if err := wo.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		testdb.Where(models.LotLink{WorkOrderId: wo.ID}).Delete(models.LotLink{})
+		testdb.Where("work_order_id = ?", wo.ID).Delete(models.Withdrawal{})
+		testdb.Where("work_order_id = ?", wo.ID).Delete(models.WorkOrderInput{})
+		testdb.Where("work_order_id = ?", wo.ID).Delete(models.WorkOrderOutput{})
+		testdb.Delete(&wo)
+		testdb.Where("product_id in (?)", []int{bulk.ID, dose.ID}).Delete(models.StockLot{})
+		testdb.Where("product_id in (?)", []int{bulk.ID, dose.ID}).Delete(models.PurchaseProduct{})
+		testdb.Delete(&bulk)
+		testdb.Delete(&dose)
+	}()
+
+	posts := make(chan *models.WorkOrder, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			posted := models.WorkOrder{ID: wo.ID}
+			if err := posted.Post(testdb, nil, models.Signoff{}); err != nil {
+				posts <- nil
+				return
+			}
+			posts <- &posted
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < 2; i++ {
+		if posted := <-posts; posted != nil {
+			wo = *posted
+			succeeded++
+		}
+	}
+
+	if succeeded != 1 {
+		t.Fatal("[ERROR] Concurrent posts should post the work order once, Got: ", succeeded)
+	}
+
+	out := wo.Outputs[0]
+	if out.LotNumber != "WO"+strconv.Itoa(wo.ID)+"-"+strconv.Itoa(out.ID) || out.ExpiresAt != expiresAt {
+		t.Error("[ERROR] Output should have its own lot expiring with the input lot, Got: ", out.LotNumber, out.ExpiresAt)
+	}
+
+	links := []models.LotLink{}
+	testdb.Where(models.LotLink{OutputLotId: out.LotId}).Find(&links)
+	if len(links) != 1 || links[0].InputLotId != input.ID {
+		t.Error("[ERROR] Output lot should be linked to the input lot, Got: ", links)
+	}
+
+	fmt.Println("[INFO] -- TestWorkOrderOutputLots end --\n")
+}
+
+func TestProductUnits(t *testing.T) {
+	fmt.Println("[INFO] -- TestProductUnits start --")
+	p := models.Product{
+		BaseUnit:     "tablet",
+		PurchaseUnit: "box",
+		DispenseUnit: "tablet",
+		Units:        []models.UnitOfMeasure{{Name: "blister", Factor: 10}, {Name: "box", Factor: 30}},
+		CurrQuantity: 35,
+		MinQuantity:  100,
+	}
+
+	if factor, err := p.Factor("blister"); err != nil || factor != 10 {
+		t.Error("[ERROR] A blister should hold 10 tablets, Got: ", factor, err)
+	}
+
+	if _, err := p.Factor("bottle"); err == nil {
+		t.Error("[ERROR] Unknown units should be refused")
+	}
+
+	if pp, err := models.NewPurchaseProduct(&p); err != nil || pp.Quantity != 3 || pp.Unit != "box" {
+		t.Error("[ERROR] 65 missing tablets should be purchased as 3 boxes, Got: ", pp, err)
+	}
+
+	p.PurchaseUnit = "crate"
+	if _, err := models.NewPurchaseProduct(&p); err == nil {
+		t.Error("[ERROR] An unknown purchasing unit should be refused")
+	}
+
+	fmt.Println("[INFO] -- TestProductUnits end --\n")
+}
+
+func TestUpdateProductUnits(t *testing.T) {
+	fmt.Println("[INFO] -- TestUpdateProductUnits start --")
+	product := models.Product{Name: "units amoxicillin", BaseUnit: "capsule", PurchaseUnit: "box", Units: []models.UnitOfMeasure{{Name: "box", Factor: 20}}, MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.UnitOfMeasure{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&product)
+	}()
+
+	edited := models.Product{ID: product.ID, Name: product.Name, BaseUnit: "capsule", PurchaseUnit: "crate", MinQuantity: 1000}
+	if err := edited.Update(testdb); err == nil {
+		t.Error("[ERROR] A purchasing unit the product doesn't have should be refused")
+	}
+
+	edited = models.Product{ID: product.ID, Name: product.Name, BaseUnit: "capsule", PurchaseUnit: "box", DispenseUnit: "box", MinQuantity: 1000}
+	if err := edited.Update(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	if base, err := (&models.Product{ID: product.ID}).ToBase(testdb, 2, ""); err != nil || base != 40 {
+		t.Error("[ERROR] Units not informed on update should be kept, Got: ", base, err)
+	}
+
+	fmt.Println("[INFO] -- TestUpdateProductUnits end --\n")
+}
+
+func TestConsumeOrSubstitute(t *testing.T) {
+	fmt.Println("[INFO] -- TestConsumeOrSubstitute start --")
+	branded := models.Product{Name: "branded dipyrone", BaseUnit: "tablet", MinQuantity: 1000}
+	generic := models.Product{Name: "generic dipyrone", BaseUnit: "tablet", CurrQuantity: 50, MinQuantity: 1000}
+	drops := models.Product{Name: "dipyrone drops", BaseUnit: "ml", CurrQuantity: 500, MinQuantity: 1000}
+	for _, p := range []*models.Product{&branded, &generic, &drops} {
+		if err := p.Save(testdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func() {
+		ids := []int{branded.ID, generic.ID, drops.ID}
+		testdb.Where("product_id in (?)", ids).Delete(models.Withdrawal{})
+		testdb.Where("product_id in (?)", ids).Delete(models.SubstitutionMember{})
+		testdb.Where("product_id in (?)", ids).Delete(models.PurchaseProduct{})
+		testdb.Where("name = ?", "dipyrone").Delete(models.SubstitutionGroup{})
+		for _, p := range []*models.Product{&branded, &generic, &drops} {
+			testdb.Delete(p)
+		}
+	}()
+
+	mixed := models.SubstitutionGroup{Name: "dipyrone", Members: []models.SubstitutionMember{{ProductId: branded.ID}, {ProductId: drops.ID, Preference: 1}}}
+	if err := mixed.Save(testdb); err == nil {
+		t.Error("[ERROR] Products with different base units shouldn't be substitutes")
+	}
+
+	sg := models.SubstitutionGroup{Name: "dipyrone", Members: []models.SubstitutionMember{{ProductId: branded.ID}, {ProductId: generic.ID, Preference: 1}}}
+	if err := sg.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	recipient := models.Withdrawal{Requester: "nurse", Department: "emergency", PatientRef: "P-1"}
+	s, err := (&models.Product{ID: branded.ID}).ConsumeOrSubstitute(testdb, 10, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ID != generic.ID {
+		t.Error("[ERROR] The generic should replace the branded drug, Got: ", s.ID)
+	}
+
+	ws := []models.Withdrawal{}
+	testdb.Where(models.Withdrawal{ProductId: generic.ID}).Find(&ws)
+	if len(ws) != 1 || ws[0].SubstitutedProductId != branded.ID || ws[0].Requester != "nurse" || ws[0].PatientRef != "P-1" {
+		t.Error("[ERROR] The substitute should be issued to the recipient, Got: ", ws)
+	}
+
+	fmt.Println("[INFO] -- TestConsumeOrSubstitute end --\n")
+}
+
+func TestBuildSignoffFromRequest(t *testing.T) {
+	fmt.Println("[INFO] -- TestBuildSignoffFromRequest start --")
+	expiresAt := time.Now().Unix() + 60
+	request := func(user string, witnessToken string) *http.Request {
+		r, _ := http.NewRequest(router.PUT, "http://127.0.0.1:8080/api/inventory/product/1/count/10", nil)
+		r.Header.Set("X-User", user)
+		r.Header.Set("X-Role", "pharmacist")
+		r.Header.Set("X-Witness-Token", witnessToken)
+		return r
+	}
+
+	signoff, err := BuildSignoffFromRequest(request("alice", token.SignSubject(ServerConfig.Register.Secret, "bob", expiresAt)))
+	if err != nil || signoff.Operator != "alice" || signoff.Witness != "bob" {
+		t.Error("[ERROR] The caller should operate and the token holder witness, Got: ", signoff, err)
+	}
+
+	if _, err := BuildSignoffFromRequest(request("alice", token.SignSubject(ServerConfig.Register.Secret, "alice", expiresAt))); err == nil {
+		t.Error("[ERROR] The caller shouldn't witness their own movement")
+	}
+
+	if _, err := BuildSignoffFromRequest(request("alice", token.SignSubject("forged", "bob", expiresAt))); err == nil {
+		t.Error("[ERROR] A witness token not signed by the server should be refused")
+	}
+
+	if signoff, err := BuildSignoffFromRequest(request("alice", "")); err != nil || signoff.Witness != "" {
+		t.Error("[ERROR] A request without witness token should have no witness, Got: ", signoff, err)
+	}
+
+	fmt.Println("[INFO] -- TestBuildSignoffFromRequest end --\n")
+}
+
+func TestControlledRegister(t *testing.T) {
+	fmt.Println("[INFO] -- TestControlledRegister start --")
+	morphine := models.Product{Name: "morphine ampoule", Controlled: true, CurrQuantity: 10, MinQuantity: 1000}
+	if err := morphine.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	kit := models.Product{Name: "pain kit", IsKit: true, MinQuantity: 100, Components: []models.KitComponent{{ComponentId: morphine.ID, Quantity: 2}}}
+	if err := kit.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		testdb.Where("product_id in (?)", []int{morphine.ID, kit.ID}).Delete(models.Withdrawal{})
+		testdb.Where("product_id in (?)", []int{morphine.ID, kit.ID}).Delete(models.PurchaseProduct{})
+		testdb.Where("product_id = ?", morphine.ID).Delete(models.RegisterEntry{})
+		testdb.Where("product_id = ?", kit.ID).Delete(models.KitComponent{})
+		testdb.Delete(&kit)
+		testdb.Delete(&morphine)
+	}()
+
+	if err := (&models.Product{ID: kit.ID}).Consume(testdb, 1); err == nil {
+		t.Error("[ERROR] A kit with a controlled component shouldn't be consumed without a witness")
+	}
+
+	signoff := models.Signoff{Operator: "nurse", Witness: "pharmacist"}
+	if err := (&models.Product{ID: kit.ID}).ConsumeWitnessed(testdb, 1, models.Withdrawal{}, signoff); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := (&models.RegisterEntry{ProductId: morphine.ID}).Retreive(testdb)
+	if len(entries) != 2 || entries[1].Movement != models.MovementStockOut || entries[1].Witness != "pharmacist" || entries[1].Balance != 8 {
+		t.Error("[ERROR] The component withdrawal should be registered with its witness, Got: ", entries)
+	}
+
+	v, err := models.VerifyRegister(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !v.Valid {
+		t.Error("[ERROR] The register should be valid, Got: ", v.Problems)
+	}
+
+	testdb.Model(&entries[1]).UpdateColumn("quantity", -1)
+	v, err = models.VerifyRegister(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Valid {
+		t.Error("[ERROR] An altered entry should break the register")
+	}
+
+	fmt.Println("[INFO] -- TestControlledRegister end --\n")
+}
+
+func TestRecallTrace(t *testing.T) {
+	fmt.Println("[INFO] -- TestRecallTrace start --")
+	expiresAt := int(time.Now().AddDate(1, 0, 0).Unix())
+	bulk := models.Product{Name: "recall bulk syrup", CurrQuantity: 100, MinQuantity: 1000}
+	dose := models.Product{Name: "recall syrup dose", MinQuantity: 1000}
+	gauze := models.Product{Name: "recall gauze", CurrQuantity: 10, MinQuantity: 1000}
+	for _, p := range []*models.Product{&bulk, &dose, &gauze} {
+		if err := p.Save(testdb); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kit := models.Product{Name: "recall dressing kit", IsKit: true, MinQuantity: 100, Components: []models.KitComponent{{ComponentId: gauze.ID, Quantity: 2}}}
+	if err := kit.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	testdb.Create(&models.StockLot{ProductId: bulk.ID, LotNumber: "RB-1", ExpiresAt: expiresAt, Quantity: 100, Status: models.LotAvailable})
+	testdb.Create(&models.StockLot{ProductId: gauze.ID, LotNumber: "RG-1", ExpiresAt: expiresAt, Quantity: 10, Status: models.LotAvailable})
+
+	wo := models.WorkOrder{
+		Inputs:  []models.WorkOrderInput{{ProductId: bulk.ID, Quantity: 10}},
+		Outputs: []models.WorkOrderOutput{{ProductId: dose.ID, ExpectedQuantity: 20}},
+	}
+	if err := wo.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	recalls := []models.Recall{}
+	defer func() {
+		ids := []int{bulk.ID, dose.ID, gauze.ID, kit.ID}
+		for _, r := range recalls {
+			testdb.Where("recall_id = ?", r.ID).Delete(models.RecallLot{})
+			testdb.Delete(&r)
+		}
+		ws := []models.Withdrawal{}
+		testdb.Where("product_id in (?)", ids).Find(&ws)
+		for _, w := range ws {
+			testdb.Where("withdrawal_id = ?", w.ID).Delete(models.WithdrawalLot{})
+			testdb.Delete(&w)
+		}
+		testdb.Where(models.LotLink{WorkOrderId: wo.ID}).Delete(models.LotLink{})
+		testdb.Where("work_order_id = ?", wo.ID).Delete(models.WorkOrderInput{})
+		testdb.Where("work_order_id = ?", wo.ID).Delete(models.WorkOrderOutput{})
+		testdb.Delete(&wo)
+		testdb.Where("product_id in (?)", ids).Delete(models.StockLot{})
+		testdb.Where("product_id in (?)", ids).Delete(models.PurchaseProduct{})
+		testdb.Where("product_id = ?", kit.ID).Delete(models.KitComponent{})
+		testdb.Delete(&kit)
+		for _, p := range []*models.Product{&bulk, &dose, &gauze} {
+			testdb.Delete(p)
+		}
+	}()
+
+	if err := wo.Post(testdb, nil, models.Signoff{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&models.Product{ID: dose.ID}).ConsumeFor(testdb, 5, models.Withdrawal{Requester: "nurse", PatientRef: "P-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&models.Product{ID: kit.ID}).ConsumeFor(testdb, 1, models.Withdrawal{Requester: "nurse", PatientRef: "P-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	bulkRecall := models.Recall{ProductId: bulk.ID, Reason: "contamination", Lots: []models.RecallLot{{LotNumber: "RB-1"}}}
+	if err := bulkRecall.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	recalls = append(recalls, bulkRecall)
+
+	if len(bulkRecall.Lots) != 2 || bulkRecall.Lots[1].ProductId != dose.ID || bulkRecall.Lots[1].QuarantinedQuantity != 15 {
+		t.Error("[ERROR] The lot produced from the recalled lot should be recalled too, Got: ", bulkRecall.Lots)
+	}
+
+	trace, err := bulkRecall.Trace(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patient := false
+	for _, tw := range trace.Withdrawals {
+		if tw.ProductId == dose.ID && tw.PatientRef == "P-1" && tw.Quantity == 5 {
+			patient = true
+		}
+	}
+
+	if !patient {
+		t.Error("[ERROR] The trace should reach the patient who received the produced doses, Got: ", trace.Withdrawals)
+	}
+
+	gauzeRecall := models.Recall{ProductId: gauze.ID, Reason: "not sterile", Lots: []models.RecallLot{{LotNumber: "RG-1"}}}
+	if err := gauzeRecall.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	recalls = append(recalls, gauzeRecall)
+
+	trace, err = gauzeRecall.Trace(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trace.Withdrawals) != 1 || trace.Withdrawals[0].KitId != kit.ID || trace.Withdrawals[0].ParentWithdrawalId == 0 || trace.Withdrawals[0].PatientRef != "P-2" {
+		t.Error("[ERROR] The component withdrawal should be traced to the kit withdrawal and its patient, Got: ", trace.Withdrawals)
+	}
+
+	fmt.Println("[INFO] -- TestRecallTrace end --\n")
+}
+
+func TestRecallReceivingQuarantine(t *testing.T) {
+	fmt.Println("[INFO] -- TestRecallReceivingQuarantine start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "receiving vaccine", MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, Value: 100}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 100}
+	testdb.Create(&purchase)
+
+	receipt := models.Receipt{PurchaseId: purchase.ID, Quarantine: true, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 10, LotNumber: "RQ-1", ExpiresAt: int(time.Now().AddDate(1, 0, 0).Unix())},
+	}}
+	recall := models.Recall{ProductId: product.ID, Reason: "contamination", Lots: []models.RecallLot{{LotNumber: "RQ-1"}}}
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.Inspection{})
+		testdb.Where("recall_id = ?", recall.ID).Delete(models.RecallLot{})
+		testdb.Where("id = ?", recall.ID).Delete(models.Recall{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.StockLot{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recall.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recall.Lots) != 1 || recall.Lots[0].LotId == 0 || recall.Lots[0].QuarantinedQuantity != 10 {
+		t.Error("[ERROR] The lot in receiving quarantine should be recalled with its quantity, Got: ", recall.Lots)
+	}
+
+	in := receipt.Inspections[0]
+	if err := in.Inspect(testdb, models.Inspection{Inspector: "qa", ReleasedQuantity: 10}, policy); err == nil || in.Status == models.InspectionCompleted {
+		t.Error("[ERROR] Goods of a recalled lot shouldn't be released, Got: ", in.Status)
+	}
+
+	lot := models.StockLot{}
+	testdb.Where(models.StockLot{ID: recall.Lots[0].LotId}).First(&lot)
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if lot.Status != models.LotRecalled || lot.Quantity != 0 || p.CurrQuantity != 0 || p.QuarantinedQuantity != 10 {
+		t.Error("[ERROR] The recalled goods should stay in quarantine, Got: ", lot, p.CurrQuantity, p.QuarantinedQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestRecallReceivingQuarantine end --\n")
+}
+
+func TestInspectionRelease(t *testing.T) {
+	fmt.Println("[INFO] -- TestInspectionRelease start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "inspection vaccine", MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, Value: 100}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 100}
+	testdb.Create(&purchase)
+
+	receipt := models.Receipt{PurchaseId: purchase.ID, Quarantine: true, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 10, LotNumber: "IN-1", ExpiresAt: int(time.Now().AddDate(1, 0, 0).Unix())},
+	}}
+	sr := models.SupplierReturn{PurchaseId: purchase.ID, Reason: "failed inspection", Lines: []models.SupplierReturnLine{
+		{PurchaseProductId: pp.ID, Quantity: 2, Stock: models.ReturnFromRejected},
+	}}
+	recall := models.Recall{ProductId: product.ID, Reason: "potency", Lots: []models.RecallLot{{LotNumber: "IN-1"}}}
+
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.Inspection{})
+		testdb.Where("recall_id = ?", recall.ID).Delete(models.RecallLot{})
+		testdb.Where("id = ?", recall.ID).Delete(models.Recall{})
+		testdb.Where("supplier_return_id = ?", sr.ID).Delete(models.SupplierReturnLine{})
+		testdb.Where("id = ?", sr.ID).Delete(models.SupplierReturn{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.StockLot{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeQuarantine}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := models.PurchaseProduct{}
+	testdb.Where(models.PurchaseProduct{ID: pp.ID}).First(&received)
+	if received.ReceivedQuantity != 0 || len(receipt.Inspections) != 1 {
+		t.Fatal("[ERROR] Goods in quarantine shouldn't count as received yet, Got: ", received.ReceivedQuantity, receipt.Inspections)
+	}
+
+	result := models.Inspection{Inspector: "qa", ReleasedQuantity: 7, RejectedQuantity: 2, DamagedQuantity: 1}
+	inspections := make(chan *models.Inspection, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			inspected := models.Inspection{ID: receipt.Inspections[0].ID}
+			if err := inspected.Inspect(testdb, result, policy); err != nil {
+				inspections <- nil
+				return
+			}
+			inspections <- &inspected
+		}()
+	}
+
+	var in models.Inspection
+	succeeded := 0
+	for i := 0; i < 2; i++ {
+		if inspected := <-inspections; inspected != nil {
+			in = *inspected
+			succeeded++
+		}
+	}
+
+	if succeeded != 1 {
+		t.Fatal("[ERROR] Concurrent inspections should record the result once, Got: ", succeeded)
+	}
+
+	testdb.Where(models.PurchaseProduct{ID: pp.ID}).First(&received)
+	if received.ReceivedQuantity != 7 {
+		t.Error("[ERROR] Only the released goods should count as received, Got: ", received.ReceivedQuantity)
+	}
+
+	edited := models.Product{ID: product.ID, Name: "inspection vaccine", CurrQuantity: 7, MinQuantity: 1000}
+	if err := edited.Update(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 7 || p.QuarantinedQuantity != 0 || p.RejectedQuantity != 2 || p.DamagedQuantity != 1 {
+		t.Error("[ERROR] Updating the product shouldn't change the inspected quantities, Got: ", p)
+	}
+
+	if err := sr.Save(testdb, models.Signoff{}); err != nil {
+		t.Fatal(err)
+	}
+
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 7 || p.RejectedQuantity != 0 {
+		t.Error("[ERROR] Rejected goods should be returned from the rejected stock, Got: ", p.CurrQuantity, p.RejectedQuantity)
+	}
+
+	hold := models.Inspection{LotId: in.LotId, Reason: "suspect potency"}
+	if err := hold.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recall.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 0 || p.QuarantinedQuantity != 7 || recall.Lots[0].QuarantinedQuantity != 7 {
+		t.Error("[ERROR] Recalling a lot on hold shouldn't quarantine it twice, Got: ", p.CurrQuantity, p.QuarantinedQuantity, recall.Lots)
+	}
+
+	if err := hold.Inspect(testdb, models.Inspection{Inspector: "qa", ReleasedQuantity: 7}, policy); err == nil {
+		t.Error("[ERROR] A recalled lot shouldn't be released")
+	}
+
+	fmt.Println("[INFO] -- TestInspectionRelease end --\n")
+}
+
+func TestShortShelfLifeReceipt(t *testing.T) {
+	fmt.Println("[INFO] -- TestShortShelfLifeReceipt start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "shelf life insulin", MinQuantity: 1000, MinShelfLifeDays: 180}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 30, Value: 300}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 300}
+	testdb.Create(&purchase)
+
+	short := int(time.Now().AddDate(0, 1, 0).Unix())
+	long := int(time.Now().AddDate(1, 0, 0).Unix())
+	rejected := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 10, LotNumber: "SL-1", ExpiresAt: short},
+	}}
+	held := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 10, LotNumber: "SL-1", ExpiresAt: short},
+		{PurchaseProductId: pp.ID, Quantity: 5, LotNumber: "SL-2", ExpiresAt: long},
+	}}
+	defer func() {
+		testdb.Where("product_id = ?", product.ID).Delete(models.Inspection{})
+		testdb.Where("receipt_id in (?)", []int{rejected.ID, held.ID}).Delete(models.ReceiptLine{})
+		testdb.Where("id in (?)", []int{rejected.ID, held.ID}).Delete(models.Receipt{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.StockLot{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := rejected.Save(testdb, policy, models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeReject}); err != nil {
+		t.Fatal(err)
+	}
+
+	line := rejected.Lines[0]
+	if !line.ShortShelfLife || !line.Rejected || line.LotId != 0 || len(rejected.Inspections) != 0 {
+		t.Error("[ERROR] Goods with a short shelf life should be rejected, Got: ", line, rejected.Inspections)
+	}
+
+	received := models.PurchaseProduct{}
+	testdb.Where(models.PurchaseProduct{ID: pp.ID}).First(&received)
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if received.ReceivedQuantity != 0 || p.CurrQuantity != 0 {
+		t.Error("[ERROR] Rejected goods shouldn't count as received nor enter the stock, Got: ", received.ReceivedQuantity, p.CurrQuantity)
+	}
+
+	if err := held.Save(testdb, policy, models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeQuarantine}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(held.Inspections) != 1 || held.Inspections[0].Quantity != 10 || held.Inspections[0].Reason != "short shelf life" || held.Lines[1].ShortShelfLife {
+		t.Error("[ERROR] Only the goods with a short shelf life should be held for inspection, Got: ", held.Inspections, held.Lines)
+	}
+
+	testdb.Where(models.PurchaseProduct{ID: pp.ID}).First(&received)
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if received.ReceivedQuantity != 5 || p.CurrQuantity != 5 || p.QuarantinedQuantity != 10 {
+		t.Error("[ERROR] Goods held for inspection should stay in quarantine, Got: ", received.ReceivedQuantity, p.CurrQuantity, p.QuarantinedQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestShortShelfLifeReceipt end --\n")
+}
+
+func TestSerialHistory(t *testing.T) {
+	fmt.Println("[INFO] -- TestSerialHistory start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "serial glucometer", Serialized: true, MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 2, Value: 200}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 200}
+	testdb.Create(&purchase)
+
+	receipt := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 2, Serials: []string{"GL-1", "GL-2"}},
+	}}
+
+	defer func() {
+		units := []models.SerialUnit{}
+		testdb.Where(models.SerialUnit{ProductId: product.ID}).Find(&units)
+		for _, u := range units {
+			testdb.Where("serial_unit_id = ?", u.ID).Delete(models.SerialEvent{})
+			testdb.Delete(&u)
+		}
+		testdb.Where("product_id = ?", product.ID).Delete(models.Withdrawal{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeQuarantine}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&models.Product{ID: product.ID}).ConsumeFor(testdb, 1, models.Withdrawal{Requester: "nurse", PatientRef: "P-1"}); err == nil {
+		t.Error("[ERROR] Serialized products shouldn't be consumed without serial numbers")
+	}
+
+	if err := (&models.Product{ID: product.ID}).ConsumeFor(testdb, 1, models.Withdrawal{Requester: "nurse", PatientRef: "P-1", Serials: []string{"GL-1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	unit := models.SerialUnit{SerialNumber: "GL-1", ProductId: product.ID}
+	if err := unit.Return(testdb, models.SerialEvent{Requester: "nurse", Reason: "patient discharged"}); err != nil {
+		t.Fatal(err)
+	}
+
+	units, err := (&models.SerialUnit{SerialNumber: "GL-1", ProductId: product.ID}).Retreive(testdb)
+	if err != nil || len(units) != 1 {
+		t.Fatal("[ERROR] Unit should be found, Got: ", units, err)
+	}
+
+	events := units[0].Events
+	if units[0].Status != models.SerialInStock || len(events) != 3 || events[0].Event != models.SerialEventReceived || events[1].Event != models.SerialEventWithdrawn || events[1].PatientRef != "P-1" || events[2].Event != models.SerialEventReturned {
+		t.Error("[ERROR] Unit should be back in stock with its whole history, Got: ", units[0])
+	}
+
+	req := models.Requisition{Department: "icu", Requester: "nurse", Lines: []models.RequisitionLine{{ProductId: product.ID, RequestedQuantity: 1}}}
+	if err := req.Save(testdb); err == nil {
+		testdb.Where("requisition_id = ?", req.ID).Delete(models.RequisitionLine{})
+		testdb.Delete(&req)
+		t.Error("[ERROR] Serialized products shouldn't be requisitioned")
+	}
+
+	fmt.Println("[INFO] -- TestSerialHistory end --\n")
+}
+
+func TestSerialQuarantine(t *testing.T) {
+	fmt.Println("[INFO] -- TestSerialQuarantine start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "quarantine glucometer", Serialized: true, MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 2, Value: 200}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 200}
+	testdb.Create(&purchase)
+
+	receipt := models.Receipt{PurchaseId: purchase.ID, Quarantine: true, Lines: []models.ReceiptLine{
+		{PurchaseProductId: pp.ID, Quantity: 2, LotNumber: "GQ-1", Serials: []string{"GQ-1-A", "GQ-1-B"}},
+	}}
+
+	defer func() {
+		units := []models.SerialUnit{}
+		testdb.Where(models.SerialUnit{ProductId: product.ID}).Find(&units)
+		for _, u := range units {
+			testdb.Where("serial_unit_id = ?", u.ID).Delete(models.SerialEvent{})
+			testdb.Delete(&u)
+		}
+		testdb.Where("product_id = ?", product.ID).Delete(models.Withdrawal{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.Inspection{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.StockLot{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	if err := receipt.Save(testdb, policy, models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeQuarantine}); err != nil {
+		t.Fatal(err)
+	}
+
+	units, err := (&models.SerialUnit{ProductId: product.ID}).Retreive(testdb)
+	if err != nil || len(units) != 2 || units[0].Status != models.SerialQuarantine || units[1].Status != models.SerialQuarantine {
+		t.Fatal("[ERROR] Units received in quarantine should be held, Got: ", units, err)
+	}
+
+	if err := (&models.Product{ID: product.ID}).ConsumeFor(testdb, 1, models.Withdrawal{Requester: "nurse", PatientRef: "P-1", Serials: []string{"GQ-1-A"}}); err == nil {
+		t.Error("[ERROR] Units in quarantine shouldn't be withdrawn")
+	}
+
+	in := models.Inspection{ID: receipt.Inspections[0].ID}
+	if err := in.Inspect(testdb, models.Inspection{Inspector: "qa", ReleasedQuantity: 1, RejectedQuantity: 1}, policy); err == nil {
+		t.Error("[ERROR] A partial release should name the released units")
+	}
+
+	result := models.Inspection{Inspector: "qa", ReleasedQuantity: 1, RejectedQuantity: 1, Notes: "cracked casing", Serials: []string{"GQ-1-A"}}
+	if err := in.Inspect(testdb, result, policy); err != nil {
+		t.Fatal(err)
+	}
+
+	released, err := (&models.SerialUnit{SerialNumber: "GQ-1-A", ProductId: product.ID}).Retreive(testdb)
+	if err != nil || len(released) != 1 || released[0].Status != models.SerialInStock || released[0].Events[len(released[0].Events)-1].Event != models.SerialEventReleased {
+		t.Error("[ERROR] The released unit should be in stock, Got: ", released, err)
+	}
+
+	rejected, err := (&models.SerialUnit{SerialNumber: "GQ-1-B", ProductId: product.ID}).Retreive(testdb)
+	if err != nil || len(rejected) != 1 || rejected[0].Status != models.SerialRejected || rejected[0].Events[len(rejected[0].Events)-1].Event != models.SerialEventRejected {
+		t.Error("[ERROR] The unit not released should be rejected, Got: ", rejected, err)
+	}
+
+	unit := models.SerialUnit{SerialNumber: "GQ-1-B", ProductId: product.ID}
+	if err := unit.Return(testdb, models.SerialEvent{Requester: "nurse", Reason: "mistake"}); err == nil {
+		t.Error("[ERROR] Rejected units shouldn't be returned to stock")
+	}
+
+	fmt.Println("[INFO] -- TestSerialQuarantine end --\n")
+}
+
+func TestTemperatureExcursion(t *testing.T) {
+	fmt.Println("[INFO] -- TestTemperatureExcursion start --")
+	now := int(time.Now().Unix())
+	product := models.Product{Name: "excursion insulin", TemperatureSensitive: true, StorageMinTemperature: 2, StorageMaxTemperature: 8, MinQuantity: 1000}
+	if err := product.Save(testdb); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Approved: true, CreatedAt: now, SubmittedAt: now, ClosedAt: now}
+	testdb.Create(&order)
+	pp := models.PurchaseProduct{ProductId: product.ID, OrderId: order.ID, Quantity: 10, Value: 100}
+	testdb.Create(&pp)
+	purchase := models.Purchase{OrderId: order.ID, CreatedAt: now, ConfirmedAt: now, TotalValue: 100}
+	testdb.Create(&purchase)
+
+	expiresAt := int(time.Now().AddDate(1, 0, 0).Unix())
+	receipt := models.Receipt{PurchaseId: purchase.ID, Lines: []models.ReceiptLine{{PurchaseProductId: pp.ID, Quantity: 10, LotNumber: "INS-1", ExpiresAt: expiresAt}}}
+	reading := models.TemperatureReading{Location: "test fridge", Temperature: 12}
+
+	defer func() {
+		testdb.Where("reading_id = ?", reading.ID).Delete(models.TemperatureExcursion{})
+		testdb.Where("id = ?", reading.ID).Delete(models.TemperatureReading{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.Inspection{})
+		testdb.Where("receipt_id = ?", receipt.ID).Delete(models.ReceiptLine{})
+		testdb.Where("id = ?", receipt.ID).Delete(models.Receipt{})
+		testdb.Where("product_id = ?", product.ID).Delete(models.StockLot{})
+		testdb.Delete(&purchase)
+		testdb.Where("product_id = ?", product.ID).Delete(models.PurchaseProduct{})
+		testdb.Delete(&order)
+		testdb.Delete(&product)
+	}()
+
+	policy := models.AllocationPolicy{Mode: models.AllocationPriority}
+	receiving := models.ReceivingPolicy{ShortShelfLife: models.ShortShelfLifeQuarantine}
+	if err := receipt.Save(testdb, policy, receiving); err == nil {
+		t.Fatal("[ERROR] Temperature sensitive products shouldn't be received without a location")
+	}
+
+	receipt.Lines[0].Location = "test fridge"
+	if err := receipt.Save(testdb, policy, receiving); err != nil {
+		t.Fatal(err)
+	}
+
+	excursions, err := reading.Save(testdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(excursions) != 1 || excursions[0].ProductId != product.ID || excursions[0].Quantity != 10 || excursions[0].InspectionId == 0 {
+		t.Fatal("[ERROR] The lot in the fridge should be put on hold, Got: ", excursions)
+	}
+
+	p := models.Product{}
+	testdb.Where(models.Product{ID: product.ID}).First(&p)
+	if p.CurrQuantity != 0 || p.QuarantinedQuantity != 10 {
+		t.Error("[ERROR] The stock of the lot should be in quarantine, Got: ", p.CurrQuantity, p.QuarantinedQuantity)
+	}
+
+	fmt.Println("[INFO] -- TestTemperatureExcursion end --\n")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillBackorderIdWithUrlValue(b *models.Backorder, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	b.ID = id
+
+	return nil
+}
+
+// BuildBackorderFromUrlValues reads the requester, department and priority of a consumption request
+func BuildBackorderFromUrlValues(params url.Values) (*models.Backorder, error) {
+	b := &models.Backorder{Requester: params.Get("requester"), Department: params.Get("department")}
+
+	if params.Get("priority") != "" {
+		priority, err := strconv.Atoi(params.Get("priority"))
+		if err != nil {
+			return nil, err
+		}
+		b.Priority = priority
+	}
+
+	return b, nil
+}
+
+func retreiveBackorder(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Backorder{}
+	if err := BuildStructFromQueryString(&b, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	bs, err := b.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(bs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, bs)
+	return nil
+}
+
+func cancelBackorder(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Backorder{}
+
+	if err := FillBackorderIdWithUrlValue(&b, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := b.Cancel(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, b)
+	return nil
+}
+
+// publishBackorderFulfillment notifies the requester that stock was withdrawn for the backorder
+func publishBackorderFulfillment(b *models.Backorder) error {
+	msg, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	producer.Publish("backorder_fulfilled", msg)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillBudgetIdWithUrlValue(b *models.Budget, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	b.ID = id
+
+	return nil
+}
+
+func retreiveBudget(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Budget{}
+	if err := BuildStructFromQueryString(&b, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	budgets, err := b.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(budgets) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, budgets)
+	return nil
+}
+
+func insertBudget(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Budget{}
+	if err := BuildStructFromReqBody(&b, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := b.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, b)
+	return nil
+}
+
+func updateBudget(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Budget{}
+
+	if err := BuildStructFromReqBody(&b, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := FillBudgetIdWithUrlValue(&b, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := b.Update(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, b)
+	return nil
+}
+
+func deleteBudget(w http.ResponseWriter, r *http.Request) errors.Http {
+	b := models.Budget{}
+	if err := FillBudgetIdWithUrlValue(&b, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := b.Delete(db); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, b)
+	return nil
+}
+
+// retreiveBudgetReport returns the consumption of the budgets of a period, the current one by default
+func retreiveBudgetReport(w http.ResponseWriter, r *http.Request) errors.Http {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = time.Now().Format("2006-01")
+	}
+
+	b := models.Budget{Period: period}
+	budgets, err := b.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, budgets)
+	return nil
+}
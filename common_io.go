@@ -33,6 +33,7 @@ func setupCommonIo() {
 	 */
 	consumer = common_io.NewConsumer(cfg)
 	consumer.HandleTopic("product_created", handleProductCreated)
+	consumer.HandleTopic("temperature_reading", handleTemperatureReading)
 
 	if err = consumer.StartListening(); err != nil {
 		log.Fatal(err.Error())
@@ -59,3 +60,23 @@ func handleProductCreated(msg []byte) {
 		producer.Publish("product_created_dead_letter", msg)
 	}
 }
+
+func handleTemperatureReading(msg []byte) {
+	fmt.Println("[INFO] Received Kafka message from topic 'temperature_reading'")
+	tr := models.TemperatureReading{}
+	if err := json.Unmarshal(msg, &tr); err != nil {
+		fmt.Println("[ERROR] Unable to Unmarshal json from message 'temperature_reading'", err.Error())
+		return
+	}
+
+	excursions, err := tr.Save(db)
+	if err != nil {
+		fmt.Println("[ERROR] Unable to save reading from message 'temperature_reading'", err.Error())
+		producer.Publish("temperature_reading_dead_letter", msg)
+		return
+	}
+
+	for _, excursion := range excursions {
+		publishTemperatureExcursion(&excursion)
+	}
+}
@@ -1,5 +1,16 @@
 package main
 
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asvins/warehouse/edi"
+	"github.com/asvins/warehouse/models"
+)
+
 // Config struct for this service
 type Config struct {
 	Server struct {
@@ -11,4 +22,178 @@ type Config struct {
 		DbName  string
 		SSLMode string
 	}
+	Approval struct {
+		Level          []string
+		OverBudget     string
+		EscalationRole string
+	}
+	Scheduler struct {
+		Weekday      string
+		Time         string
+		Threshold    float64
+		Interval     int
+		LateInterval int
+	}
+	Matching struct {
+		QuantityTolerance float64
+		PriceTolerance    float64
+	}
+	SupplierPortal struct {
+		Secret   string
+		TokenTTL int
+		BaseURL  string
+	}
+	Register struct {
+		Secret     string
+		WitnessTTL int
+	}
+	Company struct {
+		Name    string
+		Address string
+		Phone   string
+		Email   string
+	}
+	Allocation struct {
+		Mode              string
+		CriticalityWeight float64
+		AgeWeight         float64
+		Department        []string
+	}
+	Receiving struct {
+		ShortShelfLife string
+	}
+	Edi struct {
+		BuyerId         string
+		Currency        string
+		OutboundDir     string
+		Endpoint        string
+		Timeout         int
+		InboundDir      string
+		InboundInterval int
+	}
+}
+
+// ApprovalRules parses the approval levels, declared as "<threshold> <role>", and the budget policy.
+// The escalation role must be a role that no level already requires
+func (c *Config) ApprovalRules() (models.ApprovalRules, error) {
+	rules := models.ApprovalRules{Budget: models.BudgetPolicy{OverBudget: c.Approval.OverBudget, EscalationRole: c.Approval.EscalationRole}}
+	if rules.Budget.OverBudget != models.OverBudgetBlock && rules.Budget.OverBudget != models.OverBudgetEscalate {
+		return rules, errors.New("[ERROR] Invalid over budget action: '" + c.Approval.OverBudget + "'")
+	}
+
+	if rules.Budget.OverBudget == models.OverBudgetEscalate && rules.Budget.EscalationRole == "" {
+		return rules, errors.New("[ERROR] Escalation role must be informed")
+	}
+
+	for _, level := range c.Approval.Level {
+		fields := strings.Fields(level)
+		if len(fields) != 2 {
+			return rules, errors.New("[ERROR] Invalid approval level: '" + level + "'")
+		}
+
+		threshold, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return rules, err
+		}
+
+		if fields[1] == rules.Budget.EscalationRole {
+			return rules, errors.New("[ERROR] Escalation role '" + fields[1] + "' is already required by an approval level")
+		}
+
+		rules.Levels = append(rules.Levels, models.ApprovalLevel{Threshold: threshold, Role: fields[1]})
+	}
+	return rules, nil
+}
+
+// AllocationPolicy parses the allocation mode and the department weights, declared as "<department> <weight>"
+func (c *Config) AllocationPolicy() (models.AllocationPolicy, error) {
+	policy := models.AllocationPolicy{
+		Mode:              c.Allocation.Mode,
+		CriticalityWeight: c.Allocation.CriticalityWeight,
+		AgeWeight:         c.Allocation.AgeWeight,
+		Departments:       map[string]float64{},
+	}
+
+	if policy.Mode == "" {
+		policy.Mode = models.AllocationPriority
+	}
+
+	if policy.Mode != models.AllocationPriority && policy.Mode != models.AllocationFairShare {
+		return policy, errors.New("[ERROR] Invalid allocation mode: '" + c.Allocation.Mode + "'")
+	}
+
+	for _, department := range c.Allocation.Department {
+		fields := strings.Fields(department)
+		if len(fields) < 2 {
+			return policy, errors.New("[ERROR] Invalid allocation department: '" + department + "'")
+		}
+
+		weight, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return policy, err
+		}
+
+		policy.Departments[strings.Join(fields[:len(fields)-1], " ")] = weight
+	}
+	return policy, nil
+}
+
+// ReceivingPolicy parses what's done with goods received with a short shelf life, quarantine by default.
+// Over-receipts are tolerated as far as invoices are
+func (c *Config) ReceivingPolicy() (models.ReceivingPolicy, error) {
+	policy := models.ReceivingPolicy{ShortShelfLife: c.Receiving.ShortShelfLife, QuantityTolerance: c.Matching.QuantityTolerance}
+	if policy.ShortShelfLife == "" {
+		policy.ShortShelfLife = models.ShortShelfLifeQuarantine
+	}
+
+	if policy.ShortShelfLife != models.ShortShelfLifeReject && policy.ShortShelfLife != models.ShortShelfLifeQuarantine {
+		return policy, errors.New("[ERROR] Invalid short shelf life action: '" + c.Receiving.ShortShelfLife + "'")
+	}
+	return policy, nil
+}
+
+// LoadSecrets replaces the secrets of the configuration file with the ones set in the environment,
+// so the secrets of a deployment don't have to be committed
+func (c *Config) LoadSecrets() {
+	if secret := os.Getenv("WAREHOUSE_SUPPLIER_PORTAL_SECRET"); secret != "" {
+		c.SupplierPortal.Secret = secret
+	}
+
+	if secret := os.Getenv("WAREHOUSE_REGISTER_SECRET"); secret != "" {
+		c.Register.Secret = secret
+	}
+}
+
+// ValidateSupplierPortal verifies that the secret signing the supplier links was changed from the default
+func (c *Config) ValidateSupplierPortal() error {
+	if c.SupplierPortal.Secret == "" || c.SupplierPortal.Secret == "change-me" {
+		return errors.New("[ERROR] The supplier portal secret must be set to a private value")
+	}
+	return nil
+}
+
+// ValidateRegister verifies that the secret keying the controlled products register, and signing
+// the witness tokens, was changed from the default and that witness tokens expire
+func (c *Config) ValidateRegister() error {
+	if c.Register.Secret == "" || c.Register.Secret == "change-me" {
+		return errors.New("[ERROR] The register secret must be set to a private value")
+	}
+
+	if c.Register.WitnessTTL <= 0 {
+		return errors.New("[ERROR] Witness token ttl must be greater than 0")
+	}
+	return nil
+}
+
+// MatchTolerance returns the tolerances used to match supplier invoices
+func (c *Config) MatchTolerance() models.MatchTolerance {
+	return models.MatchTolerance{Quantity: c.Matching.QuantityTolerance, Price: c.Matching.PriceTolerance}
+}
+
+// EdiOutbound returns where cXML orders are sent: the endpoint when there's one, the outbound directory otherwise
+func (c *Config) EdiOutbound() edi.Outbound {
+	if c.Edi.Endpoint != "" {
+		return &edi.HTTPOutbound{URL: c.Edi.Endpoint, Timeout: time.Duration(c.Edi.Timeout) * time.Second}
+	}
+	return &edi.DirOutbound{Dir: c.Edi.OutboundDir}
 }
@@ -0,0 +1,168 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pageWidth  = 595 // A4 in points
+	pageHeight = 842
+	margin     = 50
+	lineHeight = 16
+)
+
+// pdf is a minimal PDF writer that lays out lines of text using the standard Helvetica fonts.
+// When set, header is drawn at the top of every page started because the previous one was full
+type pdf struct {
+	pages  []*bytes.Buffer
+	y      int
+	header func()
+}
+
+func newPdf() *pdf {
+	p := &pdf{}
+	p.addPage()
+	return p
+}
+
+func (p *pdf) addPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+	p.y = pageHeight - margin
+}
+
+// breakPage starts a new page, with the header, when there's no room left on the current one
+func (p *pdf) breakPage() {
+	if p.y >= margin {
+		return
+	}
+
+	p.addPage()
+	if p.header != nil {
+		header := p.header
+		p.header = nil
+		header()
+		p.header = header
+	}
+}
+
+// text writes a row of cells, each one starting at the given x position
+func (p *pdf) text(bold bool, size int, cells map[int]string) {
+	p.breakPage()
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	page := p.pages[len(p.pages)-1]
+	for x, s := range cells {
+		fmt.Fprintf(page, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, x, p.y, escape(s))
+	}
+	p.y -= lineHeight
+}
+
+// line writes a single text starting at the left margin
+func (p *pdf) line(bold bool, size int, s string) {
+	p.text(bold, size, map[int]string{margin: s})
+}
+
+func (p *pdf) skip() {
+	p.y -= lineHeight
+}
+
+// rule draws a horizontal line across the page
+func (p *pdf) rule() {
+	p.breakPage()
+	page := p.pages[len(p.pages)-1]
+	fmt.Fprintf(page, "%d %d m %d %d l S\n", margin, p.y+lineHeight/2, pageWidth-margin, p.y+lineHeight/2)
+	p.y -= lineHeight / 2
+}
+
+// WriteTo writes the document objects, the cross-reference table and the trailer
+func (p *pdf) WriteTo(w io.Writer) (int64, error) {
+	out := &bytes.Buffer{}
+	offsets := []int{}
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// 1 catalog, 2 pages, 3 and 4 fonts, then a page and its content for every page
+	kids := []string{}
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
+
+// wrap splits the text in lines of at most width characters, breaking between words when possible
+func wrap(s string, width int) []string {
+	lines := []string{}
+	current := ""
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+
+		if current == "" {
+			current = word
+		} else if len([]rune(current))+1+len([]rune(word)) <= width {
+			current += " " + word
+		} else {
+			lines = append(lines, current)
+			current = word
+		}
+	}
+
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// escape encodes the text as a PDF string using the WinAnsi (latin-1) encoding
+func escape(s string) string {
+	b := &bytes.Buffer{}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package document
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Company is the header printed on the documents sent to suppliers
+type Company struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+}
+
+// Party is the supplier the purchase order is addressed to
+type Party struct {
+	Name  string
+	Email string
+	Phone string
+}
+
+// PurchaseOrderLine is one product of the purchase order, its quantity in the purchasing unit
+type PurchaseOrderLine struct {
+	ProductName string
+	Quantity    int
+	Unit        string
+	UnitPrice   float64
+	Total       float64
+}
+
+// PurchaseOrder holds everything printed on a purchase order
+type PurchaseOrder struct {
+	Company    Company
+	Supplier   Party
+	Number     int
+	CreatedAt  int
+	ExpectedAt int
+	Lines      []PurchaseOrderLine
+	Total      float64
+}
+
+// WriteCSV writes the purchase order as CSV, with the header fields before the lines
+func (po *PurchaseOrder) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{
+		{"company", po.Company.Name},
+		{"purchase_order", strconv.Itoa(po.Number)},
+		{"created_at", formatDate(po.CreatedAt)},
+		{"expected_at", formatDate(po.ExpectedAt)},
+		{"supplier", po.Supplier.Name},
+		{"supplier_email", po.Supplier.Email},
+		{},
+		{"product", "quantity", "unit", "unit_price", "total"},
+	}
+
+	for _, line := range po.Lines {
+		records = append(records, []string{line.ProductName, strconv.Itoa(line.Quantity), line.Unit, formatMoney(line.UnitPrice), formatMoney(line.Total)})
+	}
+	records = append(records, []string{"total", "", "", "", formatMoney(po.Total)})
+
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// productNameWidth is how many characters of a product name fit before the quantity column
+const productNameWidth = 40
+
+// WritePDF renders the purchase order as a PDF document. Long product names are wrapped
+// and the header of the lines is repeated on every page
+func (po *PurchaseOrder) WritePDF(w io.Writer) error {
+	p := newPdf()
+
+	p.line(true, 16, po.Company.Name)
+	p.line(false, 10, po.Company.Address)
+	p.line(false, 10, po.Company.Phone+"  "+po.Company.Email)
+	p.skip()
+
+	p.line(true, 14, fmt.Sprintf("PURCHASE ORDER #%d", po.Number))
+	p.line(false, 10, "Date: "+formatDate(po.CreatedAt))
+	p.line(false, 10, "Promised delivery: "+formatDate(po.ExpectedAt))
+	p.skip()
+
+	p.line(true, 11, "Supplier")
+	p.line(false, 10, po.Supplier.Name)
+	p.line(false, 10, po.Supplier.Phone+"  "+po.Supplier.Email)
+	p.skip()
+
+	p.header = func() {
+		p.text(true, 10, map[int]string{margin: "Product", 300: "Quantity", 380: "Unit price", 470: "Total"})
+		p.rule()
+	}
+	p.header()
+	for _, line := range po.Lines {
+		names := wrap(line.ProductName, productNameWidth)
+		p.text(false, 10, map[int]string{margin: names[0], 300: strings.TrimSpace(strconv.Itoa(line.Quantity) + " " + line.Unit), 380: formatMoney(line.UnitPrice), 470: formatMoney(line.Total)})
+		for _, name := range names[1:] {
+			p.line(false, 10, name)
+		}
+	}
+	p.rule()
+	p.text(true, 10, map[int]string{380: "Total", 470: formatMoney(po.Total)})
+
+	_, err := p.WriteTo(w)
+	return err
+}
+
+func formatDate(timestamp int) string {
+	if timestamp == 0 {
+		return "-"
+	}
+	return time.Unix(int64(timestamp), 0).Format("2006-01-02")
+}
+
+func formatMoney(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
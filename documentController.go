@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/document"
+	"github.com/asvins/warehouse/models"
+)
+
+// newPurchaseOrderDocument gathers the supplier and product names of the purchase
+func newPurchaseOrderDocument(purchase *models.Purchase) (*document.PurchaseOrder, error) {
+	po := &document.PurchaseOrder{
+		Company:    document.Company(ServerConfig.Company),
+		Number:     purchase.ID,
+		CreatedAt:  purchase.CreatedAt,
+		ExpectedAt: purchase.ExpectedAt,
+		Total:      purchase.TotalValue,
+	}
+
+	if purchase.SupplierId != 0 {
+		supplier := models.Supplier{ID: purchase.SupplierId}
+		suppliers, err := supplier.Retreive(db)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(suppliers) == 1 {
+			po.Supplier = document.Party{Name: suppliers[0].Name, Email: suppliers[0].Email, Phone: suppliers[0].Phone}
+		}
+	}
+
+	for _, pp := range purchase.PurschaseOrder.Pproducts {
+		if pp.Quantity == 0 {
+			continue
+		}
+
+		product := models.Product{ID: pp.ProductId}
+		products, err := product.Retreive(db)
+		if err != nil {
+			return nil, err
+		}
+
+		name := "#" + strconv.Itoa(pp.ProductId)
+		if len(products) == 1 {
+			name = products[0].Name
+		}
+
+		po.Lines = append(po.Lines, document.PurchaseOrderLine{ProductName: name, Quantity: pp.Quantity, Unit: pp.Unit, UnitPrice: pp.UnitValue(), Total: pp.Value})
+	}
+
+	return po, nil
+}
+
+func retreivePurchaseDocument(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	purchases, err := purchase.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(purchases) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	po, err := newPurchaseOrderDocument(&purchases[0])
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	buf := &bytes.Buffer{}
+	filename := "purchase-order-" + strconv.Itoa(purchase.ID)
+	switch format := r.URL.Query().Get("format"); format {
+	case "pdf", "":
+		err = po.WritePDF(buf)
+		w.Header().Set("Content-Type", "application/pdf")
+		filename += ".pdf"
+	case "csv":
+		err = po.WriteCSV(buf)
+		w.Header().Set("Content-Type", "text/csv")
+		filename += ".csv"
+	default:
+		return errors.BadRequest("[ERROR] Unsupported document format: '" + format + "'")
+	}
+
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+	return nil
+}
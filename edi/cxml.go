@@ -0,0 +1,293 @@
+package edi
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	MessageConfirmation = "confirmation"
+	MessageDespatch     = "despatch"
+
+	ConfirmAccept = "accept"
+	ConfirmDetail = "detail"
+	ConfirmReject = "reject"
+
+	doctype = `<!DOCTYPE cXML SYSTEM "http://xml.cxml.org/schemas/cXML/1.2.014/cXML.dtd">` + "\n"
+)
+
+// Order is the approved purchase sent to the supplier
+type Order struct {
+	PurchaseId int
+	BuyerId    string
+	SupplierId string
+	Currency   string
+	CreatedAt  int
+	Lines      []OrderLine
+	Total      float64
+}
+
+// OrderLine is one purchase product of the order. LineNumber is the purchase product id and
+// Unit the purchasing unit, "EA" when it's empty
+type OrderLine struct {
+	LineNumber  int
+	ProductId   int
+	Description string
+	Quantity    int
+	Unit        string
+	UnitPrice   float64
+}
+
+// Message is an inbound document from the supplier about one purchase
+type Message struct {
+	Type       string
+	PurchaseId int
+	Status     string
+	Comment    string
+	DeliveryAt int
+	Lines      []MessageLine
+}
+
+// MessageLine is the confirmed or despatched quantity of one purchase product
+type MessageLine struct {
+	LineNumber int
+	Status     string
+	Quantity   int
+	DeliveryAt int
+}
+
+type cxml struct {
+	XMLName   xml.Name `xml:"cXML"`
+	PayloadID string   `xml:"payloadID,attr"`
+	Timestamp string   `xml:"timestamp,attr"`
+	Header    *header  `xml:"Header,omitempty"`
+	Request   request  `xml:"Request"`
+}
+
+type header struct {
+	From   identity `xml:"From>Credential"`
+	To     identity `xml:"To>Credential"`
+	Sender identity `xml:"Sender>Credential"`
+}
+
+type identity struct {
+	Domain   string `xml:"domain,attr"`
+	Identity string `xml:"Identity"`
+}
+
+type request struct {
+	OrderRequest        *orderRequest        `xml:"OrderRequest,omitempty"`
+	ConfirmationRequest *confirmationRequest `xml:"ConfirmationRequest,omitempty"`
+	ShipNoticeRequest   *shipNoticeRequest   `xml:"ShipNoticeRequest,omitempty"`
+}
+
+type money struct {
+	Currency string `xml:"currency,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type orderRequest struct {
+	Header orderRequestHeader `xml:"OrderRequestHeader"`
+	Items  []itemOut          `xml:"ItemOut"`
+}
+
+type orderRequestHeader struct {
+	OrderID   string `xml:"orderID,attr"`
+	OrderDate string `xml:"orderDate,attr"`
+	Type      string `xml:"type,attr"`
+	Total     money  `xml:"Total>Money"`
+}
+
+type itemOut struct {
+	Quantity       int    `xml:"quantity,attr"`
+	LineNumber     int    `xml:"lineNumber,attr"`
+	SupplierPartID string `xml:"ItemID>SupplierPartID"`
+	UnitPrice      money  `xml:"ItemDetail>UnitPrice>Money"`
+	Description    string `xml:"ItemDetail>Description"`
+	UnitOfMeasure  string `xml:"ItemDetail>UnitOfMeasure"`
+}
+
+type orderReference struct {
+	OrderID string `xml:"orderID,attr"`
+}
+
+type confirmationRequest struct {
+	Header         confirmationHeader `xml:"ConfirmationHeader"`
+	OrderReference orderReference     `xml:"OrderReference"`
+	Items          []confirmationItem `xml:"ConfirmationItem"`
+}
+
+type confirmationHeader struct {
+	Type       string `xml:"type,attr"`
+	NoticeDate string `xml:"noticeDate,attr"`
+	Comments   string `xml:"Comments"`
+}
+
+type confirmationItem struct {
+	LineNumber int                `xml:"lineNumber,attr"`
+	Quantity   int                `xml:"quantity,attr"`
+	Status     confirmationStatus `xml:"ConfirmationStatus"`
+}
+
+type confirmationStatus struct {
+	Type         string `xml:"type,attr"`
+	Quantity     int    `xml:"quantity,attr"`
+	DeliveryDate string `xml:"deliveryDate,attr"`
+}
+
+type shipNoticeRequest struct {
+	Header  shipNoticeHeader  `xml:"ShipNoticeHeader"`
+	Portion shipNoticePortion `xml:"ShipNoticePortion"`
+}
+
+type shipNoticeHeader struct {
+	ShipmentID   string `xml:"shipmentID,attr"`
+	NoticeDate   string `xml:"noticeDate,attr"`
+	DeliveryDate string `xml:"deliveryDate,attr"`
+}
+
+type shipNoticePortion struct {
+	OrderReference orderReference   `xml:"OrderReference"`
+	Items          []shipNoticeItem `xml:"ShipNoticeItem"`
+}
+
+type shipNoticeItem struct {
+	LineNumber int `xml:"lineNumber,attr"`
+	Quantity   int `xml:"quantity,attr"`
+}
+
+// MarshalOrderRequest serializes the order as a cXML OrderRequest
+func MarshalOrderRequest(o *Order) ([]byte, error) {
+	now := time.Now()
+	doc := cxml{
+		PayloadID: strconv.FormatInt(now.UnixNano(), 10) + "." + strconv.Itoa(o.PurchaseId) + "@" + o.BuyerId,
+		Timestamp: now.Format(time.RFC3339),
+		Header: &header{
+			From:   identity{Domain: "NetworkID", Identity: o.BuyerId},
+			To:     identity{Domain: "NetworkID", Identity: o.SupplierId},
+			Sender: identity{Domain: "NetworkID", Identity: o.BuyerId},
+		},
+	}
+
+	request := &orderRequest{
+		Header: orderRequestHeader{
+			OrderID:   strconv.Itoa(o.PurchaseId),
+			OrderDate: formatDate(o.CreatedAt),
+			Type:      "new",
+			Total:     money{Currency: o.Currency, Value: formatMoney(o.Total)},
+		},
+	}
+
+	for _, line := range o.Lines {
+		unit := line.Unit
+		if unit == "" {
+			unit = "EA"
+		}
+
+		request.Items = append(request.Items, itemOut{
+			Quantity:       line.Quantity,
+			LineNumber:     line.LineNumber,
+			SupplierPartID: strconv.Itoa(line.ProductId),
+			UnitPrice:      money{Currency: o.Currency, Value: formatMoney(line.UnitPrice)},
+			Description:    line.Description,
+			UnitOfMeasure:  unit,
+		})
+	}
+	doc.Request.OrderRequest = request
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header+doctype), b...), nil
+}
+
+// Unmarshal parses an inbound cXML ConfirmationRequest or ShipNoticeRequest
+func Unmarshal(data []byte) (*Message, error) {
+	doc := cxml{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case doc.Request.ConfirmationRequest != nil:
+		return parseConfirmation(doc.Request.ConfirmationRequest)
+	case doc.Request.ShipNoticeRequest != nil:
+		return parseShipNotice(doc.Request.ShipNoticeRequest)
+	}
+
+	return nil, errors.New("[ERROR] Unsupported cXML request")
+}
+
+func parseConfirmation(c *confirmationRequest) (*Message, error) {
+	purchaseId, err := strconv.Atoi(c.OrderReference.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{Type: MessageConfirmation, PurchaseId: purchaseId, Status: c.Header.Type, Comment: c.Header.Comments}
+	if msg.Status != ConfirmAccept && msg.Status != ConfirmDetail && msg.Status != ConfirmReject {
+		return nil, errors.New("[ERROR] Unsupported confirmation type: '" + msg.Status + "'")
+	}
+
+	for _, item := range c.Items {
+		deliveryAt, err := parseDate(item.Status.DeliveryDate)
+		if err != nil {
+			return nil, err
+		}
+
+		quantity := item.Status.Quantity
+		if quantity == 0 && item.Status.Type != ConfirmReject {
+			quantity = item.Quantity
+		}
+
+		msg.Lines = append(msg.Lines, MessageLine{LineNumber: item.LineNumber, Status: item.Status.Type, Quantity: quantity, DeliveryAt: deliveryAt})
+		if deliveryAt > msg.DeliveryAt {
+			msg.DeliveryAt = deliveryAt
+		}
+	}
+
+	return msg, nil
+}
+
+func parseShipNotice(s *shipNoticeRequest) (*Message, error) {
+	purchaseId, err := strconv.Atoi(s.Portion.OrderReference.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryAt, err := parseDate(s.Header.DeliveryDate)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{Type: MessageDespatch, PurchaseId: purchaseId, Comment: s.Header.ShipmentID, DeliveryAt: deliveryAt}
+	for _, item := range s.Portion.Items {
+		msg.Lines = append(msg.Lines, MessageLine{LineNumber: item.LineNumber, Quantity: item.Quantity, DeliveryAt: deliveryAt})
+	}
+
+	return msg, nil
+}
+
+func formatDate(timestamp int) string {
+	return time.Unix(int64(timestamp), 0).Format(time.RFC3339)
+}
+
+func parseDate(date string) (int, error) {
+	if date == "" {
+		return 0, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Unix()), nil
+}
+
+func formatMoney(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,109 @@
+package edi
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a post to the supplier's endpoint when HTTPOutbound has no timeout
+const defaultTimeout = 30 * time.Second
+
+// Outbound delivers serialized documents to the supplier
+type Outbound interface {
+	Send(name string, data []byte) error
+}
+
+// DirOutbound drops documents into a directory, standing in for a real EDI network
+type DirOutbound struct {
+	Dir string
+}
+
+// HTTPOutbound posts documents to the supplier's endpoint, giving up after Timeout
+type HTTPOutbound struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// Send writes the document to the directory. It's written under a temporary name
+// first so that readers never see a partial file
+func (o *DirOutbound) Send(name string, data []byte) error {
+	if err := os.MkdirAll(o.Dir, 0755); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(o.Dir, "."+name+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(o.Dir, name))
+}
+
+// Send posts the document to the endpoint
+func (o *HTTPOutbound) Send(name string, data []byte) error {
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Post(o.URL, "text/xml", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New("[ERROR] cXML endpoint answered with status " + resp.Status)
+	}
+	return nil
+}
+
+// ScanInbound hands every .xml file found in dir to handle, in name order. Handled
+// files are moved to dir/processed and the ones that failed to dir/failed
+func ScanInbound(dir string, handle func(data []byte) error) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".xml") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		target := "processed"
+		if err := handle(data); err != nil {
+			target = "failed"
+		}
+
+		if err := os.MkdirAll(filepath.Join(dir, target), 0755); err != nil {
+			return err
+		}
+
+		if err := os.Rename(path, filepath.Join(dir, target, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/edi"
+	"github.com/asvins/warehouse/models"
+)
+
+// validatePurchaseEdi verifies that the purchase can be sent to the supplier: it comes from an
+// approved order and wasn't rejected nor concluded
+func validatePurchaseEdi(purchase *models.Purchase) error {
+	if !purchase.PurschaseOrder.Approved {
+		return fmt.Errorf("[ERROR] Purchase %d doesn't come from an approved order", purchase.ID)
+	}
+
+	if purchase.RejectedAt != 0 || purchase.ConcludedAt != 0 {
+		return fmt.Errorf("[ERROR] Purchase %d is already closed", purchase.ID)
+	}
+	return nil
+}
+
+// sendPurchaseEdi serializes the purchase as a cXML OrderRequest and sends it to the supplier
+func sendPurchaseEdi(purchase *models.Purchase) error {
+	if err := validatePurchaseEdi(purchase); err != nil {
+		return err
+	}
+
+	o := &edi.Order{
+		PurchaseId: purchase.ID,
+		BuyerId:    ServerConfig.Edi.BuyerId,
+		SupplierId: strconv.Itoa(purchase.SupplierId),
+		Currency:   ServerConfig.Edi.Currency,
+		CreatedAt:  purchase.CreatedAt,
+		Total:      purchase.TotalValue,
+	}
+
+	for _, pp := range purchase.PurschaseOrder.Pproducts {
+		if pp.Quantity == 0 {
+			continue
+		}
+
+		product := models.Product{ID: pp.ProductId}
+		products, err := product.Retreive(db)
+		if err != nil {
+			return err
+		}
+
+		description := ""
+		if len(products) == 1 {
+			description = products[0].Name
+		}
+
+		o.Lines = append(o.Lines, edi.OrderLine{LineNumber: pp.ID, ProductId: pp.ProductId, Description: description, Quantity: pp.Quantity, Unit: pp.Unit, UnitPrice: pp.UnitValue()})
+	}
+
+	b, err := edi.MarshalOrderRequest(o)
+	if err != nil {
+		return err
+	}
+
+	return ServerConfig.EdiOutbound().Send("order-"+strconv.Itoa(purchase.ID)+".xml", b)
+}
+
+// processEdiInbound applies every confirmation and despatch advice dropped into the inbound directory
+func processEdiInbound() {
+	if ServerConfig.Edi.InboundDir == "" {
+		return
+	}
+
+	err := edi.ScanInbound(ServerConfig.Edi.InboundDir, func(data []byte) error {
+		msg, err := edi.Unmarshal(data)
+		if err != nil {
+			fmt.Println("[ERROR] Unable to parse cXML message: ", err.Error())
+			return err
+		}
+
+		if err := applyEdiMessage(msg); err != nil {
+			fmt.Println("[ERROR] Unable to apply cXML message to purchase", msg.PurchaseId, ": ", err.Error())
+			return err
+		}
+		return nil
+	})
+
+	if err != nil {
+		fmt.Println("[ERROR] Unable to scan cXML inbound directory: ", err.Error())
+	}
+}
+
+func applyEdiMessage(msg *edi.Message) error {
+	purchase := models.Purchase{ID: msg.PurchaseId}
+
+	if msg.Type == edi.MessageDespatch {
+		despatched := make(map[int]int)
+		for _, line := range msg.Lines {
+			despatched[line.LineNumber] += line.Quantity
+		}
+		return purchase.ApplyDespatch(db, despatched, msg.DeliveryAt)
+	}
+
+	response := models.SupplierResponse{ExpectedAt: msg.DeliveryAt, Comment: msg.Comment}
+	switch msg.Status {
+	case edi.ConfirmReject:
+		if response.Comment == "" {
+			response.Comment = "Rejected through cXML"
+		}
+		return purchase.SupplierReject(db, &response)
+
+	case edi.ConfirmDetail:
+		purchases, err := purchase.Retreive(db)
+		if err != nil {
+			return err
+		}
+
+		if len(purchases) != 1 {
+			return fmt.Errorf("record not found")
+		}
+
+		for _, line := range msg.Lines {
+			for _, pp := range purchases[0].PurschaseOrder.Pproducts {
+				if pp.ID == line.LineNumber && (line.Status == edi.ConfirmReject || line.Quantity != pp.Quantity) {
+					response.Lines = append(response.Lines, models.PurchaseChange{PurchaseProductId: pp.ID, Quantity: line.Quantity, ExpectedAt: line.DeliveryAt, Rejected: line.Status == edi.ConfirmReject})
+				}
+			}
+		}
+	}
+
+	return purchase.SupplierConfirm(db, &response)
+}
+
+func sendPurchaseOrderRequest(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	purchases, err := purchase.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(purchases) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	if err := validatePurchaseEdi(&purchases[0]); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sendPurchaseEdi(&purchases[0]); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, purchase.ID)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillInspectionIdWithUrlValue(in *models.Inspection, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	in.ID = id
+
+	return nil
+}
+
+func retreiveInspection(w http.ResponseWriter, r *http.Request) errors.Http {
+	in := models.Inspection{}
+	if err := BuildStructFromQueryString(&in, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	ins, err := in.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(ins) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, ins)
+	return nil
+}
+
+func retreiveInspectionById(w http.ResponseWriter, r *http.Request) errors.Http {
+	in := models.Inspection{}
+
+	if err := FillInspectionIdWithUrlValue(&in, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	ins, err := in.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(ins) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, ins[0])
+	return nil
+}
+
+// insertInspection puts a lot on hold, expecting its lot_id and the reason
+func insertInspection(w http.ResponseWriter, r *http.Request) errors.Http {
+	in := models.Inspection{}
+	if err := BuildStructFromReqBody(&in, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := in.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, in)
+	return nil
+}
+
+// inspectInspection expects the released, rejected and damaged quantities and the inspector
+func inspectInspection(w http.ResponseWriter, r *http.Request) errors.Http {
+	in := models.Inspection{}
+	result := models.Inspection{}
+
+	if err := FillInspectionIdWithUrlValue(&in, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&result, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := in.Inspect(db, result, allocationPolicy); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	for _, b := range in.Backorders {
+		publishBackorderFulfillment(&b)
+	}
+
+	rend.JSON(w, http.StatusOK, in)
+	return nil
+}
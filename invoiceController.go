@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillInvoiceIdWithUrlValue(inv *models.Invoice, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	inv.ID = id
+
+	return nil
+}
+
+func retreiveInvoice(w http.ResponseWriter, r *http.Request) errors.Http {
+	inv := models.Invoice{}
+	if err := BuildStructFromQueryString(&inv, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	invs, err := inv.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(invs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, invs)
+	return nil
+}
+
+func retreiveInvoiceById(w http.ResponseWriter, r *http.Request) errors.Http {
+	inv := models.Invoice{}
+
+	if err := FillInvoiceIdWithUrlValue(&inv, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	invs, err := inv.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(invs) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, invs[0])
+	return nil
+}
+
+func insertPurchaseInvoice(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+	inv := models.Invoice{}
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&inv, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	inv.PurchaseId = purchase.ID
+	if err := inv.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := inv.Match(db, ServerConfig.MatchTolerance()); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, inv)
+	return nil
+}
+
+func matchInvoice(w http.ResponseWriter, r *http.Request) errors.Http {
+	inv := models.Invoice{}
+
+	if err := FillInvoiceIdWithUrlValue(&inv, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := inv.Match(db, ServerConfig.MatchTolerance()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, inv)
+	return nil
+}
@@ -0,0 +1,187 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	AllocationPriority  = "priority"
+	AllocationFairShare = "fairshare"
+
+	AllocationBackorder   = "backorder"
+	AllocationRequisition = "requisition"
+	AllocationWithdrawal  = "withdrawal"
+)
+
+// AllocationPolicy decides who gets a product when its stock doesn't cover every request.
+// Requests are scored by the criticality of the patient, the weight of the department and
+// the age of the request in hours. With the priority mode the highest scores are served
+// first, with the fairshare mode the stock is rationed equally and the leftovers follow the score
+type AllocationPolicy struct {
+	Mode              string
+	CriticalityWeight float64
+	AgeWeight         float64
+	Departments       map[string]float64
+}
+
+// AllocationRequest is a request competing for the stock of a product
+type AllocationRequest struct {
+	Source      string `json:"source"`
+	SourceId    int    `json:"source_id"`
+	Criticality int    `json:"criticality"`
+	Department  string `json:"department"`
+	Requester   string `json:"requester"`
+	CreatedAt   int    `json:"created_at"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Allocation is the quantity granted to a request
+type Allocation struct {
+	Request   AllocationRequest `json:"request"`
+	Score     float64           `json:"score"`
+	Allocated int               `json:"allocated"`
+}
+
+// AllocationLog records an allocation decision
+type AllocationLog struct {
+	ID          int     `json:"id"`
+	ProductId   int     `json:"product_id"`
+	Source      string  `json:"source" sql:"size:255"`
+	SourceId    int     `json:"source_id"`
+	Mode        string  `json:"mode" sql:"size:255"`
+	Available   int     `json:"available"`
+	Demand      int     `json:"demand"`
+	Requested   int     `json:"requested"`
+	Allocated   int     `json:"allocated"`
+	Score       float64 `json:"score"`
+	Criticality int     `json:"criticality"`
+	Department  string  `json:"department" sql:"size:255"`
+	Requester   string  `json:"requester" sql:"size:255"`
+	DecidedAt   int     `json:"decided_at"`
+}
+
+// Retreive allocation logs from database
+func (al *AllocationLog) Retreive(db *gorm.DB) ([]AllocationLog, error) {
+	var logs []AllocationLog
+	err := db.Where(*al).Order("decided_at desc, id").Find(&logs).Error
+	return logs, err
+}
+
+// Score returns the priority of the request at the given time
+func (policy AllocationPolicy) Score(req AllocationRequest, now int) float64 {
+	age := float64(now-req.CreatedAt) / 3600
+	if age < 0 {
+		age = 0
+	}
+	return policy.CriticalityWeight*float64(req.Criticality) + policy.Departments[req.Department] + policy.AgeWeight*age
+}
+
+// Allocate distributes the available quantity among the requests. The allocations are
+// returned in the order of the requests
+func (policy AllocationPolicy) Allocate(available int, requests []AllocationRequest, now int) []Allocation {
+	allocations := make([]Allocation, len(requests))
+	for i, req := range requests {
+		allocations[i] = Allocation{Request: req, Score: policy.Score(req, now)}
+	}
+
+	ranked := make(byScore, len(allocations))
+	for i := range allocations {
+		ranked[i] = &allocations[i]
+	}
+	sort.Stable(ranked)
+
+	remaining := available
+	if policy.Mode == AllocationFairShare {
+		pending := ranked
+		for remaining > 0 && len(pending) > 0 {
+			share := remaining / len(pending)
+			if share == 0 {
+				share = 1
+			}
+
+			next := byScore{}
+			for _, a := range pending {
+				grant := minQuantity(minQuantity(share, a.Request.Quantity-a.Allocated), remaining)
+				a.Allocated += grant
+				remaining -= grant
+				if a.Allocated < a.Request.Quantity {
+					next = append(next, a)
+				}
+			}
+			pending = next
+		}
+		return allocations
+	}
+
+	for _, a := range ranked {
+		a.Allocated = minQuantity(a.Request.Quantity, remaining)
+		remaining -= a.Allocated
+	}
+	return allocations
+}
+
+// allocate runs the policy over the requests for the product and logs every decision. Callers
+// must apply all the allocations returned
+func (policy AllocationPolicy) allocate(db *gorm.DB, productId int, available int, requests []AllocationRequest) ([]Allocation, error) {
+	allocations := policy.Allocate(available, requests, int(time.Now().Unix()))
+	return allocations, policy.log(db, productId, available, requests, allocations)
+}
+
+// log records the allocations that were applied out of a decision taken over the requests
+func (policy AllocationPolicy) log(db *gorm.DB, productId int, available int, requests []AllocationRequest, applied []Allocation) error {
+	now := int(time.Now().Unix())
+	demand := 0
+	for _, req := range requests {
+		demand += req.Quantity
+	}
+
+	mode := policy.Mode
+	if mode == "" {
+		mode = AllocationPriority
+	}
+
+	for _, a := range applied {
+		entry := AllocationLog{
+			ProductId:   productId,
+			Source:      a.Request.Source,
+			SourceId:    a.Request.SourceId,
+			Mode:        mode,
+			Available:   available,
+			Demand:      demand,
+			Requested:   a.Request.Quantity,
+			Allocated:   a.Allocated,
+			Score:       a.Score,
+			Criticality: a.Request.Criticality,
+			Department:  a.Request.Department,
+			Requester:   a.Request.Requester,
+			DecidedAt:   now,
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// byScore sorts allocations by highest score and, on ties, by oldest request
+type byScore []*Allocation
+
+func (a byScore) Len() int      { return len(a) }
+func (a byScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byScore) Less(i, j int) bool {
+	if a[i].Score != a[j].Score {
+		return a[i].Score > a[j].Score
+	}
+	return a[i].Request.CreatedAt < a[j].Request.CreatedAt
+}
+
+func minQuantity(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	BackorderOpen      = "open"
+	BackorderFulfilled = "fulfilled"
+	BackorderCanceled  = "canceled"
+)
+
+// Backorder is the remainder of a consumption request that couldn't be withdrawn for lack of stock.
+// Open backorders are fulfilled when a purchase receipt increases the stock of the product,
+// following the allocation policy. Priority is the criticality of the patient
+type Backorder struct {
+	ID                int    `json:"id"`
+	ProductId         int    `json:"product_id"`
+	Quantity          int    `json:"quantity"`
+	FulfilledQuantity int    `json:"fulfilled_quantity"`
+	Priority          int    `json:"priority"`
+	Requester         string `json:"requester" sql:"size:255"`
+	Department        string `json:"department" sql:"size:255"`
+	Status            string `json:"status" sql:"size:255"`
+	CreatedAt         int    `json:"created_at"`
+	FulfilledAt       int    `json:"fulfilled_at"`
+}
+
+// Outstanding returns the quantity still waiting for stock
+func (b *Backorder) Outstanding() int {
+	return b.Quantity - b.FulfilledQuantity
+}
+
+// Retreive backorders from database, highest priority and oldest first
+func (b *Backorder) Retreive(db *gorm.DB) ([]Backorder, error) {
+	var bs []Backorder
+	err := db.Where(*b).Order("priority desc, created_at, id").Find(&bs).Error
+	return bs, err
+}
+
+// Cancel an open backorder
+func (b *Backorder) Cancel(db *gorm.DB) error {
+	bs, err := (&Backorder{ID: b.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(bs) != 1 {
+		return errors.New("record not found")
+	}
+
+	if bs[0].Status != BackorderOpen {
+		return errors.New("[ERROR] Only open backorders can be canceled")
+	}
+
+	*b = bs[0]
+	b.Status = BackorderCanceled
+	return db.Model(b).UpdateColumn("status", b.Status).Error
+}
+
+// ConsumeOrBackorder withdraws what is available of the requested quantity and queues the remainder
+// as a backorder. The backorder is only saved when there is a remainder
+func (p *Product) ConsumeOrBackorder(db *gorm.DB, quantity int, backorder *Backorder) error {
+	if quantity <= 0 {
+		return errors.New("[ERROR] Requested quantity must be greater than 0")
+	}
+
+	var pp Product
+	if err := db.Where(*p).First(&pp).Error; err != nil {
+		return err
+	}
+
+	if err := pp.loadAvailability(db); err != nil {
+		return err
+	}
+
+	witness, err := pp.requiresWitness(db)
+	if err != nil {
+		return err
+	}
+
+	if witness {
+		return errWitnessRequired
+	}
+
+	if pp.Serialized {
+		return errSerialsRequired
+	}
+
+	available := quantity
+	if pp.CurrQuantity < available {
+		available = pp.CurrQuantity
+	}
+
+	tx := db.Begin()
+	if available > 0 {
+		w := NewWithdrawl(pp, available)
+		w.Requester = backorder.Requester
+		w.Department = backorder.Department
+		if err := withdrawStock(tx, w, Signoff{}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if available < quantity {
+		backorder.ID = 0
+		backorder.ProductId = pp.ID
+		backorder.Quantity = quantity - available
+		backorder.FulfilledQuantity = 0
+		backorder.Status = BackorderOpen
+		backorder.CreatedAt = int(time.Now().Unix())
+		backorder.FulfilledAt = 0
+		if err := tx.Create(backorder).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// fulfillBackordersOfStock fulfills the open backorders of the products whose stock increased and
+// of the kits they are part of. The backorders that received any quantity are returned
+func fulfillBackordersOfStock(db *gorm.DB, productIds []int, policy AllocationPolicy) ([]Backorder, error) {
+	backorders := []Backorder{}
+	fulfilled := map[int]bool{}
+	for _, id := range productIds {
+		kits, err := kitsWithComponent(db, id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, productId := range append([]int{id}, kits...) {
+			if fulfilled[productId] {
+				continue
+			}
+			fulfilled[productId] = true
+
+			served, err := fulfillBackorders(db, productId, policy)
+			if err != nil {
+				return nil, err
+			}
+			backorders = append(backorders, served...)
+		}
+	}
+
+	return backorders, nil
+}
+
+// fulfillBackorders withdraws the stock of the product for its open backorders, as decided by
+// the allocation policy. Products that require a witness are left open. The backorders that
+// received any quantity are returned
+func fulfillBackorders(db *gorm.DB, productId int, policy AllocationPolicy) ([]Backorder, error) {
+	open, err := (&Backorder{ProductId: productId, Status: BackorderOpen}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(open) == 0 {
+		return []Backorder{}, nil
+	}
+
+	p := Product{}
+	if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+		return nil, err
+	}
+
+	if err := p.loadAvailability(db); err != nil {
+		return nil, err
+	}
+
+	witness, err := p.requiresWitness(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if witness {
+		return []Backorder{}, nil
+	}
+
+	requests := make([]AllocationRequest, len(open))
+	for i, b := range open {
+		requests[i] = b.allocationRequest()
+	}
+
+	allocations, err := policy.allocate(db, productId, p.CurrQuantity, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	served := []Backorder{}
+	for i, a := range allocations {
+		if a.Allocated == 0 {
+			continue
+		}
+
+		b := open[i]
+		w := NewWithdrawl(p, a.Allocated)
+		w.BackorderId = b.ID
+		w.Requester = b.Requester
+		w.Department = b.Department
+		if err := withdrawStock(db, w, Signoff{}); err != nil {
+			return nil, err
+		}
+
+		b.FulfilledQuantity += a.Allocated
+		if b.Outstanding() == 0 {
+			b.Status = BackorderFulfilled
+			b.FulfilledAt = int(time.Now().Unix())
+		}
+
+		if err := db.Model(&b).UpdateColumns(Backorder{FulfilledQuantity: b.FulfilledQuantity, Status: b.Status, FulfilledAt: b.FulfilledAt}).Error; err != nil {
+			return nil, err
+		}
+		served = append(served, b)
+	}
+
+	return served, nil
+}
+
+// allocationRequest returns the backorder as a request for stock. Its priority is the criticality
+func (b *Backorder) allocationRequest() AllocationRequest {
+	return AllocationRequest{
+		Source:      AllocationBackorder,
+		SourceId:    b.ID,
+		Criticality: b.Priority,
+		Department:  b.Department,
+		Requester:   b.Requester,
+		CreatedAt:   b.CreatedAt,
+		Quantity:    b.Outstanding(),
+	}
+}
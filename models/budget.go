@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	OverBudgetBlock    = "block"
+	OverBudgetEscalate = "escalate"
+
+	periodLayout = "2006-01"
+)
+
+// Budget is the amount that can be spent on purchases in a month. It can be restricted
+// to the orders of a cost center and to the products of a type
+type Budget struct {
+	ID          int     `json:"id"`
+	Period      string  `json:"period" sql:"size:7"`
+	CostCenter  string  `json:"cost_center" sql:"size:255"`
+	ProductType int     `json:"product_type"`
+	Amount      float64 `json:"amount"`
+	Committed   float64 `json:"committed" sql:"-"`
+	Actual      float64 `json:"actual" sql:"-"`
+	Available   float64 `json:"available" sql:"-"`
+}
+
+// BudgetPolicy defines what happens to approvals that would exceed a budget:
+// they are either blocked or escalated to an additional role
+type BudgetPolicy struct {
+	OverBudget     string
+	EscalationRole string
+}
+
+// Save new budget on database
+func (b *Budget) Save(db *gorm.DB) error {
+	if _, err := time.Parse(periodLayout, b.Period); err != nil {
+		return errors.New("[ERROR] Period must have the format YYYY-MM")
+	}
+	return db.Create(b).Error
+}
+
+// Update budget on database
+func (b *Budget) Update(db *gorm.DB) error {
+	if _, err := time.Parse(periodLayout, b.Period); err != nil {
+		return errors.New("[ERROR] Period must have the format YYYY-MM")
+	}
+	return db.Save(b).Error
+}
+
+// Delete budget on database
+func (b *Budget) Delete(db *gorm.DB) error {
+	return db.Where(b).Delete(Budget{}).Error
+}
+
+// Retreive budgets from database along with their consumption
+func (b *Budget) Retreive(db *gorm.DB) ([]Budget, error) {
+	var budgets []Budget
+	if err := db.Where(*b).Find(&budgets).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range budgets {
+		if err := budgets[i].computeConsumption(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return budgets, nil
+}
+
+// computeConsumption sums the spend committed by the purchases created in the period that
+// are not concluded yet and the actual spend of the purchases concluded in the period.
+// Purchases rejected by the supplier don't consume the budget
+func (b *Budget) computeConsumption(db *gorm.DB) error {
+	start, err := time.ParseInLocation(periodLayout, b.Period, time.Local)
+	if err != nil {
+		return err
+	}
+	from, to := int(start.Unix()), int(start.AddDate(0, 1, 0).Unix())
+
+	committed := []Purchase{}
+	if err := db.Where("concluded_at = 0 and rejected_at = 0 and created_at >= ? and created_at < ?", from, to).Find(&committed).Error; err != nil {
+		return err
+	}
+
+	actual := []Purchase{}
+	if err := db.Where("rejected_at = 0 and concluded_at >= ? and concluded_at < ?", from, to).Find(&actual).Error; err != nil {
+		return err
+	}
+
+	b.Committed, b.Actual = 0, 0
+	for _, p := range committed {
+		spend, err := b.purchaseSpend(db, p)
+		if err != nil {
+			return err
+		}
+		b.Committed += spend
+	}
+
+	for _, p := range actual {
+		spend, err := b.purchaseSpend(db, p)
+		if err != nil {
+			return err
+		}
+		b.Actual += spend
+	}
+
+	b.Available = b.Amount - b.Committed - b.Actual
+	return nil
+}
+
+func (b *Budget) purchaseSpend(db *gorm.DB, p Purchase) (float64, error) {
+	orders, err := (&Order{ID: p.OrderId}).Retreive(db)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(orders) != 1 {
+		return 0, nil
+	}
+
+	spend, err := b.orderSpend(db, &orders[0])
+	if err != nil {
+		return 0, err
+	}
+
+	// credit notes reduce the spend proportionally to the purchase total
+	if p.TotalValue > 0 {
+		spend *= (p.TotalValue - p.CreditedValue) / p.TotalValue
+	}
+	return spend, nil
+}
+
+// orderSpend returns the value of the order lines that are covered by the budget
+func (b *Budget) orderSpend(db *gorm.DB, order *Order) (float64, error) {
+	if b.CostCenter != "" && b.CostCenter != order.CostCenter {
+		return 0, nil
+	}
+
+	spend := 0.0
+	for _, pp := range order.Pproducts {
+		if b.ProductType != 0 {
+			product := Product{}
+			if err := db.Where(Product{ID: pp.ProductId}).First(&product).Error; err != nil {
+				return 0, err
+			}
+
+			if product.Type != b.ProductType {
+				continue
+			}
+		}
+		spend += pp.Value
+	}
+	return spend, nil
+}
+
+// exceededBudgets returns the budgets of the current period that the order would exceed
+func (order *Order) exceededBudgets(db *gorm.DB) ([]Budget, error) {
+	budgets, err := (&Budget{Period: time.Now().Format(periodLayout)}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	exceeded := []Budget{}
+	for _, b := range budgets {
+		spend, err := b.orderSpend(db, order)
+		if err != nil {
+			return nil, err
+		}
+
+		if spend > 0 && spend > b.Available {
+			exceeded = append(exceeded, b)
+		}
+	}
+
+	return exceeded, nil
+}
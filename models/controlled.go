@@ -0,0 +1,327 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	MovementOpening     = "opening"
+	MovementConsumption = "consumption"
+	MovementAdjustment  = "adjustment"
+	MovementCount       = "count"
+	MovementStockIn     = "stock_in"
+	MovementStockOut    = "stock_out"
+	MovementQuarantine  = "quarantine"
+)
+
+var errWitnessRequired = errors.New("[ERROR] Controlled products must be consumed with a witness")
+
+// RegisterSecret keys the hashes of the register entries, so that someone with access to the
+// database alone can't rebuild the chain after altering it
+var RegisterSecret string
+
+// Signoff identifies who performed a movement and who witnessed it. Controlled products
+// can only be consumed, adjusted and counted with a witness other than the operator
+type Signoff struct {
+	Operator string `json:"operator"`
+	Witness  string `json:"witness"`
+}
+
+// RegisterEntry is a movement of a controlled product. Entries are chained: each one holds the
+// keyed hash of the previous, so altering or deleting any of them breaks the chain
+type RegisterEntry struct {
+	ID           int    `json:"id"`
+	Sequence     int    `json:"sequence"`
+	ProductId    int    `json:"product_id"`
+	Movement     string `json:"movement" sql:"size:255"`
+	Quantity     int    `json:"quantity"`
+	Balance      int    `json:"balance"`
+	Operator     string `json:"operator" sql:"size:255"`
+	Witness      string `json:"witness" sql:"size:255"`
+	Reference    string `json:"reference" sql:"size:255"`
+	RecordedAt   int    `json:"recorded_at"`
+	PreviousHash string `json:"previous_hash" sql:"size:64"`
+	Hash         string `json:"hash" sql:"size:64"`
+}
+
+// RegisterVerification is the result of checking the register chain
+type RegisterVerification struct {
+	Valid    bool     `json:"valid"`
+	Entries  int      `json:"entries"`
+	Problems []string `json:"problems"`
+}
+
+func (s Signoff) validate() error {
+	if s.Operator == "" || s.Witness == "" {
+		return errors.New("[ERROR] Controlled products require an operator and a witness")
+	}
+
+	if s.Operator == s.Witness {
+		return errors.New("[ERROR] The witness must be someone other than the operator")
+	}
+	return nil
+}
+
+// requiresWitness tells if withdrawing the product needs the signoff of a witness: controlled
+// products and kits with a controlled component do
+func (p *Product) requiresWitness(db *gorm.DB) (bool, error) {
+	if p.Controlled || !p.IsKit {
+		return p.Controlled, nil
+	}
+
+	components := []KitComponent{}
+	if err := db.Where(KitComponent{ProductId: p.ID}).Find(&components).Error; err != nil {
+		return false, err
+	}
+
+	for _, c := range components {
+		component := Product{}
+		if err := db.Where(Product{ID: c.ComponentId}).First(&component).Error; err != nil {
+			return false, err
+		}
+
+		if component.Controlled {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Retreive register entries from database, in sequence
+func (re *RegisterEntry) Retreive(db *gorm.DB) ([]RegisterEntry, error) {
+	var entries []RegisterEntry
+	err := db.Where(*re).Order("sequence").Find(&entries).Error
+	return entries, err
+}
+
+// computeHash returns the HMAC, keyed by RegisterSecret, of the entry's content chained to the
+// previous hash
+func (re *RegisterEntry) computeHash() string {
+	content := fmt.Sprintf("%d|%d|%s|%d|%d|%s|%s|%s|%d|%s",
+		re.Sequence, re.ProductId, re.Movement, re.Quantity, re.Balance,
+		re.Operator, re.Witness, re.Reference, re.RecordedAt, re.PreviousHash)
+	mac := hmac.New(sha256.New, []byte(RegisterSecret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// registerMovement appends a movement of a controlled product to the register. It must run in a
+// transaction: the register is locked until the transaction ends, so concurrent movements can't
+// chain to the same entry
+func registerMovement(db *gorm.DB, p *Product, movement string, quantity int, reference string, signoff Signoff) error {
+	if err := db.Exec("LOCK TABLE register_entries IN EXCLUSIVE MODE").Error; err != nil {
+		return err
+	}
+
+	last := []RegisterEntry{}
+	if err := db.Order("sequence desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	entry := RegisterEntry{
+		Sequence:   1,
+		ProductId:  p.ID,
+		Movement:   movement,
+		Quantity:   quantity,
+		Balance:    p.CurrQuantity,
+		Operator:   signoff.Operator,
+		Witness:    signoff.Witness,
+		Reference:  reference,
+		RecordedAt: int(time.Now().Unix()),
+	}
+
+	if len(last) == 1 {
+		entry.Sequence = last[0].Sequence + 1
+		entry.PreviousHash = last[0].Hash
+	}
+	entry.Hash = entry.computeHash()
+
+	return db.Create(&entry).Error
+}
+
+// VerifyRegister walks the register checking that the sequence has no gaps, that each entry is
+// chained to the previous one and that its content still matches its hash. The balance of the
+// last entry of each product is checked against its current quantity
+func VerifyRegister(db *gorm.DB) (*RegisterVerification, error) {
+	entries, err := (&RegisterEntry{}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &RegisterVerification{Entries: len(entries), Problems: []string{}}
+	previous := ""
+	balances := map[int]int{}
+	for i, entry := range entries {
+		if entry.Sequence != i+1 {
+			v.Problems = append(v.Problems, "entry "+strconv.Itoa(i+1)+" is missing, found "+strconv.Itoa(entry.Sequence)+" instead")
+		}
+
+		if entry.PreviousHash != previous {
+			v.Problems = append(v.Problems, "entry "+strconv.Itoa(entry.Sequence)+" isn't chained to the previous entry")
+		}
+
+		if entry.computeHash() != entry.Hash {
+			v.Problems = append(v.Problems, "entry "+strconv.Itoa(entry.Sequence)+" was altered")
+		}
+
+		previous = entry.Hash
+		balances[entry.ProductId] = entry.Balance
+	}
+
+	for productId, balance := range balances {
+		p := Product{}
+		if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+			v.Problems = append(v.Problems, "product "+strconv.Itoa(productId)+" of the register doesn't exist")
+			continue
+		}
+
+		if p.CurrQuantity != balance {
+			v.Problems = append(v.Problems, "product "+strconv.Itoa(productId)+" has "+strconv.Itoa(p.CurrQuantity)+" in stock but the register ends with "+strconv.Itoa(balance))
+		}
+	}
+
+	v.Valid = len(v.Problems) == 0
+	return v, nil
+}
+
+// ConsumeWitnessed consumes the product like ConsumeFor, registering who did it and who witnessed it.
+// Controlled products, and kits with controlled components, can only be consumed this way
+func (p *Product) ConsumeWitnessed(db *gorm.DB, quantity int, recipient Withdrawal, signoff Signoff) error {
+	var pp Product
+	if err := db.Where(*p).First(&pp).Error; err != nil {
+		return err
+	}
+
+	if pp.IsKit {
+		return pp.consumeKit(db, quantity, recipient, signoff)
+	}
+
+	if !pp.Controlled {
+		return p.ConsumeFor(db, quantity, recipient)
+	}
+
+	if err := signoff.validate(); err != nil {
+		return err
+	}
+
+	if pp.CurrQuantity-quantity < 0 {
+		return errors.New("Requested quantity exceeds the available amount")
+	}
+
+	tx := db.Begin()
+	pp.CurrQuantity -= quantity
+	if err := pp.Update(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	lots, err := takeFromLots(tx, pp.ID, 0, quantity)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	w := NewWithdrawl(pp, quantity)
+	w.issuedTo(recipient)
+	w.Lots = withdrawalLots(lots)
+	if err := w.Save(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if pp.Serialized {
+		if err := withdrawSerials(tx, w, recipient.Serials); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := registerMovement(tx, &pp, MovementConsumption, -quantity, "withdrawal "+strconv.Itoa(w.ID), signoff); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Adjust corrects the stock of the product by quantity, which is negative to decrease it.
+// Adjustments of controlled products require a witness
+func (p *Product) Adjust(db *gorm.DB, quantity int, reason string, signoff Signoff) error {
+	return p.adjust(db, MovementAdjustment, quantity, reason, signoff)
+}
+
+// Count sets the stock of the product to the quantity counted, adjusting the difference.
+// Counts of controlled products require a witness
+func (p *Product) Count(db *gorm.DB, counted int, signoff Signoff) error {
+	if counted < 0 {
+		return errors.New("[ERROR] Counted quantity can't be negative")
+	}
+
+	pp := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&pp).Error; err != nil {
+		return err
+	}
+
+	return p.adjust(db, MovementCount, counted-pp.CurrQuantity, "counted "+strconv.Itoa(counted), signoff)
+}
+
+func (p *Product) adjust(db *gorm.DB, movement string, quantity int, reason string, signoff Signoff) error {
+	if reason == "" {
+		return errors.New("[ERROR] A reason must be informed when adjusting stock")
+	}
+
+	pp := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&pp).Error; err != nil {
+		return err
+	}
+
+	if pp.IsKit {
+		return errors.New("[ERROR] The stock of kits is adjusted through their components")
+	}
+
+	if pp.Controlled {
+		if err := signoff.validate(); err != nil {
+			return err
+		}
+	}
+
+	if pp.CurrQuantity+quantity < 0 {
+		return errors.New("[ERROR] Adjustment would leave the stock negative")
+	}
+
+	tx := db.Begin()
+	pp.CurrQuantity += quantity
+	if err := pp.Update(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if quantity < 0 {
+		if _, err := takeFromLots(tx, pp.ID, 0, -quantity); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if pp.Controlled {
+		if err := registerMovement(tx, &pp, movement, quantity, reason, signoff); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	*p = pp
+	return nil
+}
@@ -0,0 +1,326 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	InspectionPending   = "pending"
+	InspectionCompleted = "completed"
+	InspectionCanceled  = "canceled"
+)
+
+// Inspection is a quantity of a product held in quarantine until someone checks it, either goods
+// received in quarantine or a lot put on hold. The inspection splits the quantity into released,
+// which becomes available, rejected and damaged. Backorders holds the backorders fulfilled with
+// the released stock. Serials names the units released of serialized goods
+type Inspection struct {
+	ID               int         `json:"id"`
+	ProductId        int         `json:"product_id"`
+	ReceiptLineId    int         `json:"receipt_line_id"`
+	LotId            int         `json:"lot_id"`
+	LotNumber        string      `json:"lot_number" sql:"size:255"`
+	ExpiresAt        int         `json:"expires_at"`
+	Location         string      `json:"location" sql:"size:255"`
+	Quantity         int         `json:"quantity"`
+	Reason           string      `json:"reason" sql:"size:255"`
+	Status           string      `json:"status" sql:"size:255"`
+	ReleasedQuantity int         `json:"released_quantity"`
+	RejectedQuantity int         `json:"rejected_quantity"`
+	DamagedQuantity  int         `json:"damaged_quantity"`
+	Inspector        string      `json:"inspector" sql:"size:255"`
+	Notes            string      `json:"notes" sql:"size:255"`
+	CreatedAt        int         `json:"created_at"`
+	InspectedAt      int         `json:"inspected_at"`
+	Backorders       []Backorder `json:"backorders" sql:"-"`
+	Serials          []string    `json:"serials,omitempty" sql:"-"`
+}
+
+// Save puts the available stock of a lot on hold until it's inspected
+func (in *Inspection) Save(db *gorm.DB) error {
+	if in.LotId == 0 {
+		return errors.New("[ERROR] The lot to put on hold must be informed")
+	}
+
+	if in.Reason == "" {
+		return errors.New("[ERROR] A reason must be informed when putting stock on hold")
+	}
+
+	tx := db.Begin()
+	if err := holdLotForInspection(tx, in); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Retreive inspections from database
+func (in *Inspection) Retreive(db *gorm.DB) ([]Inspection, error) {
+	var ins []Inspection
+	err := db.Where(*in).Order("created_at, id").Find(&ins).Error
+	return ins, err
+}
+
+// Inspect records the result of the inspection: the released quantity becomes available stock,
+// the rejected and damaged quantities are kept apart. The whole quantity on hold must be accounted
+// for. Goods released from a receipt count as received by its purchase, and serialized units not
+// released are rejected. Backorders waiting for the product are fulfilled with the released stock
+func (in *Inspection) Inspect(db *gorm.DB, result Inspection, policy AllocationPolicy) error {
+	ins, err := (&Inspection{ID: in.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(ins) != 1 {
+		return errors.New("record not found")
+	}
+	*in = ins[0]
+
+	if in.Status != InspectionPending {
+		return errors.New("[ERROR] Inspection is already " + in.Status)
+	}
+
+	if result.Inspector == "" {
+		return errors.New("[ERROR] The inspector must be informed")
+	}
+
+	if result.ReleasedQuantity < 0 || result.RejectedQuantity < 0 || result.DamagedQuantity < 0 {
+		return errors.New("[ERROR] Inspected quantities can't be negative")
+	}
+
+	if result.ReleasedQuantity+result.RejectedQuantity+result.DamagedQuantity != in.Quantity {
+		return errors.New("[ERROR] Released, rejected and damaged quantities must add up to the quantity on hold")
+	}
+
+	// completing the inspection first locks it, concurrent inspections find it no longer pending
+	tx := db.Begin()
+	update := tx.Model(in).Where("status = ?", InspectionPending).UpdateColumns(Inspection{Status: InspectionCompleted})
+	if update.Error != nil {
+		tx.Rollback()
+		return update.Error
+	}
+
+	if update.RowsAffected != 1 {
+		tx.Rollback()
+		return errors.New("[ERROR] Inspection is no longer " + InspectionPending)
+	}
+
+	in.ReleasedQuantity = result.ReleasedQuantity
+	in.RejectedQuantity = result.RejectedQuantity
+	in.DamagedQuantity = result.DamagedQuantity
+	in.Inspector = result.Inspector
+	in.Notes = result.Notes
+	in.Status = InspectionCompleted
+	in.InspectedAt = int(time.Now().Unix())
+
+	p := Product{}
+	if err := tx.Where(Product{ID: in.ProductId}).First(&p).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&p).UpdateColumns(map[string]interface{}{
+		"quarantined_quantity": p.QuarantinedQuantity - in.Quantity,
+		"rejected_quantity":    p.RejectedQuantity + in.RejectedQuantity,
+		"damaged_quantity":     p.DamagedQuantity + in.DamagedQuantity,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := in.releaseLot(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if p.Serialized && in.ReceiptLineId != 0 {
+		if err := inspectSerials(tx, in, result.Serials); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if in.ReleasedQuantity > 0 {
+		if err := addStock(tx, in.ProductId, in.ReleasedQuantity); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := in.receiveReleased(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Save(in).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	in.Backorders = []Backorder{}
+	if in.ReleasedQuantity > 0 {
+		served, err := fulfillBackordersOfStock(tx, []int{in.ProductId}, policy)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		in.Backorders = served
+	}
+
+	return tx.Commit().Error
+}
+
+// cancel closes a pending inspection whose stock must stay in quarantine, like the stock of a
+// recalled lot. Nothing of it is released. Inspections completed meanwhile can't be canceled
+func (in *Inspection) cancel(db *gorm.DB, notes string) error {
+	in.Status = InspectionCanceled
+	in.Notes = notes
+	in.InspectedAt = int(time.Now().Unix())
+	update := db.Model(in).Where("status = ?", InspectionPending).UpdateColumns(Inspection{Status: in.Status, Notes: in.Notes, InspectedAt: in.InspectedAt})
+	if update.Error != nil {
+		return update.Error
+	}
+
+	if update.RowsAffected != 1 {
+		return errors.New("[ERROR] Inspection is no longer " + InspectionPending)
+	}
+	return nil
+}
+
+// releaseLot gives the lot of the inspection the released quantity. A lot put on hold becomes
+// available again unless nothing of it was released; goods received in quarantine with a lot
+// number are added to their lot. Recalled lots can't be released
+func (in *Inspection) releaseLot(db *gorm.DB) error {
+	if in.LotId == 0 {
+		if in.LotNumber == "" || in.ReleasedQuantity == 0 {
+			return nil
+		}
+
+		lot, err := addLotStock(db, in.ProductId, in.LotNumber, in.ExpiresAt, in.ReleasedQuantity)
+		if err != nil {
+			return err
+		}
+		in.LotId = lot.ID
+		return moveLot(db, lot, in.Location)
+	}
+
+	lot := StockLot{}
+	if err := db.Where(StockLot{ID: in.LotId}).First(&lot).Error; err != nil {
+		return err
+	}
+
+	if lot.Status == LotRecalled {
+		return errors.New("[ERROR] Lot " + lot.LotNumber + " is recalled and can't be released")
+	}
+
+	status := LotAvailable
+	if in.ReleasedQuantity == 0 && in.RejectedQuantity > 0 {
+		status = LotRejected
+	} else if in.ReleasedQuantity == 0 {
+		status = LotDamaged
+	}
+
+	return db.Model(&StockLot{ID: in.LotId}).UpdateColumns(map[string]interface{}{"status": status, "quantity": in.ReleasedQuantity}).Error
+}
+
+// receiveReleased counts the released quantity as received by the purchase product of the receipt
+// line the goods came from, in its purchasing unit. Fractions of a purchasing unit aren't counted
+func (in *Inspection) receiveReleased(db *gorm.DB) error {
+	if in.ReceiptLineId == 0 {
+		return nil
+	}
+
+	line := ReceiptLine{}
+	if err := db.Where(ReceiptLine{ID: in.ReceiptLineId}).First(&line).Error; err != nil {
+		return err
+	}
+
+	pp := PurchaseProduct{}
+	if err := db.Where(PurchaseProduct{ID: line.PurchaseProductId}).First(&pp).Error; err != nil {
+		return err
+	}
+
+	factor, err := unitFactor(db, pp.ProductId, pp.Unit)
+	if err != nil {
+		return err
+	}
+
+	pp.ReceivedQuantity += in.ReleasedQuantity / factor
+	return db.Model(&pp).UpdateColumn(PurchaseProduct{ReceivedQuantity: pp.ReceivedQuantity}).Error
+}
+
+// heldForInspection sums the quantities of a purchase product, in its purchasing unit, received in
+// quarantine and still waiting for inspection
+func heldForInspection(db *gorm.DB, purchaseProductId int) (int, error) {
+	lines := []ReceiptLine{}
+	if err := db.Where("purchase_product_id = ? and id in (select receipt_line_id from inspections where status = ?)", purchaseProductId, InspectionPending).Find(&lines).Error; err != nil {
+		return 0, err
+	}
+
+	held := 0
+	for _, line := range lines {
+		held += line.Quantity
+	}
+	return held, nil
+}
+
+// holdForInspection keeps the goods of a receipt line in quarantine until they are inspected
+func holdForInspection(db *gorm.DB, line ReceiptLine, reason string) (*Inspection, error) {
+	p := Product{}
+	if err := db.Where(Product{ID: line.ProductId}).First(&p).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&p).UpdateColumn("quarantined_quantity", p.QuarantinedQuantity+line.BaseQuantity).Error; err != nil {
+		return nil, err
+	}
+
+	in := &Inspection{
+		ProductId:     line.ProductId,
+		ReceiptLineId: line.ID,
+		LotNumber:     line.LotNumber,
+		ExpiresAt:     line.ExpiresAt,
+		Location:      line.Location,
+		Quantity:      line.BaseQuantity,
+		Reason:        reason,
+		Status:        InspectionPending,
+		CreatedAt:     int(time.Now().Unix()),
+	}
+	return in, db.Create(in).Error
+}
+
+// holdLotForInspection moves the stock of an available lot to quarantine until it's inspected
+func holdLotForInspection(db *gorm.DB, in *Inspection) error {
+	lot := StockLot{}
+	if err := db.Where(StockLot{ID: in.LotId}).First(&lot).Error; err != nil {
+		return err
+	}
+
+	if lot.Status != LotAvailable {
+		return errors.New("[ERROR] Lot " + lot.LotNumber + " is " + lot.Status)
+	}
+
+	if err := quarantineLot(db, &lot, LotQuarantine); err != nil {
+		return err
+	}
+
+	in.ID = 0
+	in.ProductId = lot.ProductId
+	in.ReceiptLineId = 0
+	in.LotNumber = lot.LotNumber
+	in.ExpiresAt = lot.ExpiresAt
+	in.Location = lot.Location
+	in.Quantity = lot.Quantity
+	in.Status = InspectionPending
+	in.ReleasedQuantity = 0
+	in.RejectedQuantity = 0
+	in.DamagedQuantity = 0
+	in.Inspector = ""
+	in.InspectedAt = 0
+	in.CreatedAt = int(time.Now().Unix())
+	return db.Create(in).Error
+}
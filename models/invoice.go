@@ -0,0 +1,203 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	InvoicePending  = "pending"
+	InvoiceMismatch = "mismatch"
+	InvoiceApproved = "approved"
+)
+
+// Invoice is the bill sent by the supplier of a purchase
+type Invoice struct {
+	ID         int           `json:"id"`
+	PurchaseId int           `json:"purchase_id"`
+	SupplierId int           `json:"supplier_id"`
+	Number     string        `json:"number" sql:"size:255"`
+	IssuedAt   int           `json:"issued_at"`
+	Status     string        `json:"status" sql:"size:255"`
+	MatchedAt  int           `json:"matched_at"`
+	Lines      []InvoiceLine `json:"lines"`
+}
+
+// InvoiceLine is the quantity and unit price billed for one purchase product
+type InvoiceLine struct {
+	ID                int     `json:"id"`
+	InvoiceId         int     `json:"invoice_id"`
+	PurchaseProductId int     `json:"purchase_product_id"`
+	Quantity          int     `json:"quantity"`
+	UnitPrice         float64 `json:"unit_price"`
+	Discrepancy       string  `json:"discrepancy" sql:"size:255"`
+}
+
+// MatchTolerance is how far, as a fraction, an invoice line may be from what was ordered and received
+type MatchTolerance struct {
+	Quantity float64
+	Price    float64
+}
+
+// Total returns the amount billed on the invoice
+func (inv *Invoice) Total() float64 {
+	total := 0.0
+	for _, line := range inv.Lines {
+		total += line.UnitPrice * float64(line.Quantity)
+	}
+	return total
+}
+
+// Save new invoice on database. Every line must bill a positive quantity and price of a product of the purchase
+func (inv *Invoice) Save(db *gorm.DB) error {
+	purchase, err := retreiveSinglePurchase(db, inv.PurchaseId)
+	if err != nil {
+		return err
+	}
+
+	if len(inv.Lines) == 0 {
+		return errors.New("[ERROR] Invoice must have at least one line")
+	}
+
+	for _, line := range inv.Lines {
+		if line.Quantity <= 0 || line.UnitPrice <= 0 {
+			return errors.New("[ERROR] Invoiced quantity and unit price must be greater than 0")
+		}
+
+		if findPurchaseProduct(purchase.PurschaseOrder.Pproducts, line.PurchaseProductId) == nil {
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+	}
+
+	inv.ID = 0
+	inv.SupplierId = purchase.SupplierId
+	inv.Status = InvoicePending
+	inv.MatchedAt = 0
+	if inv.IssuedAt == 0 {
+		inv.IssuedAt = int(time.Now().Unix())
+	}
+
+	return db.Create(inv).Error
+}
+
+// Retreive invoice from database
+func (inv *Invoice) Retreive(db *gorm.DB) ([]Invoice, error) {
+	var invs []Invoice
+	if err := db.Where(*inv).Find(&invs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, invoice := range invs {
+		lines := []InvoiceLine{}
+		if err := db.Model(invoice).Related(&lines, "Lines").Error; err != nil {
+			return nil, err
+		}
+		invs[i].Lines = lines
+	}
+
+	return invs, nil
+}
+
+// Match compares every invoice line against the ordered and received quantities and the
+// ordered price of its purchase product. Quantities already billed on approved invoices of
+// the purchase, and quantities returned to the supplier, can't be billed again. The invoice
+// is approved for payment when no line is out of the tolerance
+func (inv *Invoice) Match(db *gorm.DB, tol MatchTolerance) error {
+	invs, err := (&Invoice{ID: inv.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(invs) != 1 {
+		return errors.New("record not found")
+	}
+	*inv = invs[0]
+
+	if inv.Status == InvoiceApproved {
+		return errors.New("[ERROR] Invoice was already approved for payment")
+	}
+
+	purchase, err := retreiveSinglePurchase(db, inv.PurchaseId)
+	if err != nil {
+		return err
+	}
+
+	invoiced, err := inv.alreadyInvoiced(db)
+	if err != nil {
+		return err
+	}
+
+	inv.Status = InvoiceApproved
+	for i, line := range inv.Lines {
+		pp := findPurchaseProduct(purchase.PurschaseOrder.Pproducts, line.PurchaseProductId)
+		if pp == nil {
+			line.Discrepancy = "purchase product is not part of the purchase"
+		} else {
+			line.Discrepancy = tol.Discrepancy(line, *pp, invoiced[line.PurchaseProductId])
+			invoiced[line.PurchaseProductId] += line.Quantity
+		}
+
+		if line.Discrepancy != "" {
+			inv.Status = InvoiceMismatch
+		}
+
+		if err := db.Model(&line).UpdateColumn("discrepancy", line.Discrepancy).Error; err != nil {
+			return err
+		}
+		inv.Lines[i] = line
+	}
+
+	inv.MatchedAt = int(time.Now().Unix())
+	return db.Model(inv).UpdateColumns(Invoice{Status: inv.Status, MatchedAt: inv.MatchedAt}).Error
+}
+
+// Discrepancy describes why an invoice line doesn't match the purchase product, given the
+// quantity of it already invoiced, or returns an empty string when it's within the tolerance.
+// Only the quantity received and not returned can be billed
+func (tol MatchTolerance) Discrepancy(line InvoiceLine, pp PurchaseProduct, invoiced int) string {
+	if exceeds(float64(invoiced+line.Quantity), float64(pp.Quantity), tol.Quantity) {
+		return fmt.Sprintf("invoiced quantity %d, %d already invoiced, exceeds ordered quantity %d", line.Quantity, invoiced, pp.Quantity)
+	}
+
+	kept := pp.ReceivedQuantity - pp.ReturnedQuantity
+	if exceeds(float64(invoiced+line.Quantity), float64(kept), tol.Quantity) {
+		return fmt.Sprintf("invoiced quantity %d, %d already invoiced, exceeds received quantity %d less %d returned", line.Quantity, invoiced, pp.ReceivedQuantity, pp.ReturnedQuantity)
+	}
+
+	orderedPrice := pp.UnitValue()
+	if math.Abs(line.UnitPrice-orderedPrice) > orderedPrice*tol.Price {
+		return fmt.Sprintf("invoiced unit price %.2f differs from ordered unit price %.2f", line.UnitPrice, orderedPrice)
+	}
+
+	return ""
+}
+
+// alreadyInvoiced sums, by purchase product, the quantities billed on the other approved invoices of the purchase
+func (inv *Invoice) alreadyInvoiced(db *gorm.DB) (map[int]int, error) {
+	approved := []Invoice{}
+	if err := db.Where("purchase_id = ? and status = ? and id <> ?", inv.PurchaseId, InvoiceApproved, inv.ID).Find(&approved).Error; err != nil {
+		return nil, err
+	}
+
+	invoiced := map[int]int{}
+	for _, other := range approved {
+		lines := []InvoiceLine{}
+		if err := db.Where(InvoiceLine{InvoiceId: other.ID}).Find(&lines).Error; err != nil {
+			return nil, err
+		}
+
+		for _, line := range lines {
+			invoiced[line.PurchaseProductId] += line.Quantity
+		}
+	}
+	return invoiced, nil
+}
+
+// exceeds verifies if value is greater than reference by more than the tolerated fraction
+func exceeds(value float64, reference float64, tolerance float64) bool {
+	return value > reference*(1+tolerance)
+}
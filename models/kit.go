@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// KitComponent is the quantity of a product that goes into one unit of a kit
+type KitComponent struct {
+	ID          int `json:"id"`
+	ProductId   int `json:"product_id"`
+	ComponentId int `json:"component_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// validateKit checks the components of a kit exist and aren't kits themselves. Kits and their
+// components can't be serialized
+func (p *Product) validateKit(db *gorm.DB) error {
+	if !p.IsKit {
+		if len(p.Components) != 0 {
+			return errors.New("[ERROR] Only kits can have components")
+		}
+		return nil
+	}
+
+	if p.Serialized {
+		return errors.New("[ERROR] Kits can't be serialized")
+	}
+
+	if len(p.Components) == 0 {
+		return errors.New("[ERROR] Kit must have at least one component")
+	}
+
+	for i, c := range p.Components {
+		if c.Quantity <= 0 {
+			return errors.New("[ERROR] Component quantity must be greater than 0")
+		}
+
+		component := Product{}
+		if err := db.Where(Product{ID: c.ComponentId}).First(&component).Error; err != nil {
+			return err
+		}
+
+		if component.IsKit || component.ID == p.ID {
+			return errors.New("[ERROR] A kit can't be a component of another kit")
+		}
+
+		if component.Serialized {
+			return errors.New("[ERROR] Serialized products can't be kit components")
+		}
+
+		p.Components[i].ID = 0
+		p.Components[i].ProductId = p.ID
+	}
+
+	return nil
+}
+
+// loadAvailability loads the components of a kit and derives its current quantity: the kits
+// already assembled plus the kits that can be assembled from the stock of the components
+func (p *Product) loadAvailability(db *gorm.DB) error {
+	if !p.IsKit {
+		return nil
+	}
+
+	components := []KitComponent{}
+	if err := db.Where(KitComponent{ProductId: p.ID}).Find(&components).Error; err != nil {
+		return err
+	}
+	p.Components = components
+
+	buildable := 0
+	for i, c := range components {
+		component := Product{}
+		if err := db.Where(Product{ID: c.ComponentId}).First(&component).Error; err != nil {
+			return err
+		}
+
+		kits := component.CurrQuantity / c.Quantity
+		if i == 0 || kits < buildable {
+			buildable = kits
+		}
+	}
+
+	p.CurrQuantity = p.AssembledQuantity + buildable
+	return nil
+}
+
+// removeComponentsStock withdraws quantity kits, the assembled ones first and then their components,
+// controlled components with the signoff. The quantities taken from the lots of assembled kits are
+// returned, along with the withdrawals of the components, which are saved by saveComponentWithdrawals
+func (p *Product) removeComponentsStock(db *gorm.DB, quantity int, signoff Signoff) ([]StockLot, []Withdrawal, error) {
+	if err := p.loadAvailability(db); err != nil {
+		return nil, nil, err
+	}
+
+	if p.CurrQuantity < quantity {
+		return nil, nil, errors.New("Requested quantity exceeds the available amount")
+	}
+
+	taken := []StockLot{}
+	assembled := minQuantity(p.AssembledQuantity, quantity)
+	if assembled > 0 {
+		p.AssembledQuantity -= assembled
+		if err := db.Model(p).UpdateColumn("assembled_quantity", p.AssembledQuantity).Error; err != nil {
+			return nil, nil, err
+		}
+
+		lots, err := takeFromLots(db, p.ID, 0, assembled)
+		if err != nil {
+			return nil, nil, err
+		}
+		taken = lots
+	}
+
+	components := []Withdrawal{}
+	quantity -= assembled
+	if quantity == 0 {
+		return taken, components, nil
+	}
+
+	for _, c := range p.Components {
+		lots, err := removeStockFromLot(db, c.ComponentId, 0, quantity*c.Quantity, signoff)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		w := Withdrawal{ProductId: c.ComponentId, Quantity: quantity * c.Quantity, IssuedAt: int(time.Now().Unix()), KitId: p.ID}
+		w.Lots = withdrawalLots(lots)
+		components = append(components, w)
+	}
+
+	return taken, components, nil
+}
+
+// saveComponentWithdrawals saves the withdrawals of the components of a kit as part of the withdrawal
+// of the kit, issued to the same recipient. Without a kit withdrawal they only record the kit
+func saveComponentWithdrawals(db *gorm.DB, components []Withdrawal, kit *Withdrawal) error {
+	for _, c := range components {
+		if kit != nil {
+			c.ParentWithdrawalId = kit.ID
+			c.issuedTo(*kit)
+			c.Serials = nil
+		}
+
+		if err := c.Save(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeKit withdraws the components of quantity kits in one transaction
+func (p *Product) consumeKit(db *gorm.DB, quantity int, recipient Withdrawal, signoff Signoff) error {
+	tx := db.Begin()
+	w := NewWithdrawl(*p, quantity)
+	w.issuedTo(recipient)
+	if err := p.issue(tx, w, 0, signoff); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// kitsWithComponent returns the ids of the kits the product is a component of
+func kitsWithComponent(db *gorm.DB, productId int) ([]int, error) {
+	components := []KitComponent{}
+	if err := db.Where(KitComponent{ComponentId: productId}).Find(&components).Error; err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for _, c := range components {
+		ids = append(ids, c.ProductId)
+	}
+	return ids, nil
+}
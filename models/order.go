@@ -10,12 +10,17 @@ import (
 
 //Order is the struct that defines the purchase order
 type Order struct {
-	ID        int               `json:"id"`
-	Approved  bool              `json:"approved"`
-	Canceled  bool              `json:"canceled"`
-	CreatedAt int               `json:"created_at"`
-	ClosedAt  int               `json:"closed_at"`
-	Pproducts []PurchaseProduct `json:"purchase_products"`
+	ID          int               `json:"id"`
+	Approved    bool              `json:"approved"`
+	Canceled    bool              `json:"canceled"`
+	Rejected    bool              `json:"rejected"`
+	CreatedAt   int               `json:"created_at"`
+	SubmittedAt int               `json:"submitted_at"`
+	ClosedAt    int               `json:"closed_at"`
+	SupplierId  int               `json:"supplier_id"`
+	CostCenter  string            `json:"cost_center" sql:"size:255"`
+	Pproducts   []PurchaseProduct `json:"purchase_products"`
+	Approvals   []OrderApproval   `json:"approvals"`
 }
 
 //////////////////////////////////////////////////////////////////////////////////
@@ -36,6 +41,13 @@ func (order *Order) Retreive(db *gorm.DB) ([]Order, error) {
 			return nil, err
 		}
 		o.Pproducts = pproducts
+
+		approvals := []OrderApproval{}
+		if err := db.Model(o).Related(&approvals, "Approvals").Error; err != nil {
+			fmt.Println("[ERROR] ", err.Error())
+			return nil, err
+		}
+		o.Approvals = approvals
 		orders[i] = o
 	}
 
@@ -52,28 +64,330 @@ func (order *Order) Update(db *gorm.DB) error {
 	return db.Save(order).Error
 }
 
-func (order *Order) Approve(db *gorm.DB) error {
-	if err := db.Model(order).UpdateColumn(Order{Approved: true, ClosedAt: int(time.Now().Unix())}).Error; err != nil {
+// TotalValue returns the sum of the values of the order's purchase products
+func (order *Order) TotalValue() float64 {
+	totalValue := 0.0
+	for _, pp := range order.Pproducts {
+		totalValue += pp.Value
+	}
+	return totalValue
+}
+
+// Approve records the decision of an approver over a submitted order. The order is only
+// closed, and its Purchase created, once every role required by the rules has approved it
+func (order *Order) Approve(db *gorm.DB, rules ApprovalRules, decision *OrderApproval) error {
+	if err := order.retreiveOpen(db); err != nil {
 		return err
 	}
 
-	orders, err := order.Retreive(db)
+	if order.SubmittedAt == 0 {
+		return errors.New("[ERROR] Order must be submitted before being approved")
+	}
+
+	required, err := order.requiredRoles(db, rules)
 	if err != nil {
 		return err
 	}
 
-	if len(orders) != 1 {
-		return errors.New("[ERROR] Query for recently approved order failed")
+	tx := db.Begin()
+	if len(required) == 0 {
+		if err := order.close(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit().Error
 	}
 
-	NewPurchaseFromOrder(&orders[0]).Save(db)
-	return nil
+	if err := decision.validate(required, order.Approvals); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	decision.OrderId = order.ID
+	decision.Approved = true
+	if err := decision.Save(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	order.Approvals = append(order.Approvals, *decision)
+
+	if allRolesApproved(required, order.Approvals) {
+		if err := order.close(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Submit closes the order for new products. If the rules don't require any approver,
+// the order is approved right away and its Purchase is created. When the order can't
+// be submitted, because it exceeds a budget that blocks it, it's kept open
+func (order *Order) Submit(db *gorm.DB, rules ApprovalRules) error {
+	if err := order.retreiveOpen(db); err != nil {
+		return err
+	}
+
+	if order.SubmittedAt != 0 {
+		return errors.New("[ERROR] Order was already submitted")
+	}
+
+	tx := db.Begin()
+	if err := order.submit(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := order.closeIfNoApproval(tx, rules); err != nil {
+		tx.Rollback()
+		order.SubmittedAt = 0
+		order.Approved = false
+		order.ClosedAt = 0
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Award assigns each line to the supplier of the chosen quote, filling in its value.
+// Awarded lines are split into one submitted order per supplier; lines not awarded
+// stay on this order. Nothing is awarded when any award is invalid or a split order
+// is blocked by its budget
+func (order *Order) Award(db *gorm.DB, rules ApprovalRules, awards []Award) ([]Order, error) {
+	if err := order.retreiveDraft(db); err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+	for _, award := range awards {
+		line, err := order.lineById(award.PurchaseProductId)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		quote := Quote{}
+		if err := tx.Where(Quote{ID: award.QuoteId, PurchaseProductId: line.ID}).First(&quote).Error; err != nil {
+			tx.Rollback()
+			return nil, errors.New("[ERROR] Quote not found for purchase product")
+		}
+
+		if quote.ValidUntil < int(time.Now().Unix()) {
+			tx.Rollback()
+			return nil, errors.New("[ERROR] Quote is no longer valid")
+		}
+
+		line.Value = quote.Price * float64(line.Quantity)
+		line.SupplierId = quote.SupplierId
+		line.Manual = true
+		if err := tx.Save(line).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if err := tx.Model(&quote).UpdateColumn(Quote{Awarded: true}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Model(Rfq{}).Where("order_id = ? and closed_at = ?", order.ID, 0).UpdateColumn(Rfq{ClosedAt: int(time.Now().Unix())}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	orders, err := order.splitBySupplier(tx, rules)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return orders, tx.Commit().Error
+}
+
+// splitBySupplier moves the awarded lines to one submitted order per supplier.
+// When every line was awarded, this order is kept for the first supplier
+func (order *Order) splitBySupplier(db *gorm.DB, rules ApprovalRules) ([]Order, error) {
+	supplierIds := []int{}
+	linesBySupplier := make(map[int][]PurchaseProduct)
+	for _, line := range order.Pproducts {
+		if line.Quantity == 0 {
+			continue
+		}
+
+		if _, ok := linesBySupplier[line.SupplierId]; !ok && line.SupplierId != 0 {
+			supplierIds = append(supplierIds, line.SupplierId)
+		}
+		linesBySupplier[line.SupplierId] = append(linesBySupplier[line.SupplierId], line)
+	}
+
+	orders := []Order{}
+	for i, supplierId := range supplierIds {
+		if i == 0 && len(linesBySupplier[0]) == 0 {
+			order.SupplierId = supplierId
+			order.Pproducts = linesBySupplier[supplierId]
+			if err := db.Model(order).UpdateColumn(Order{SupplierId: supplierId}).Error; err != nil {
+				return nil, err
+			}
+
+			if err := order.submit(db); err != nil {
+				return nil, err
+			}
+
+			if err := order.closeIfNoApproval(db, rules); err != nil {
+				return nil, err
+			}
+
+			orders = append(orders, *order)
+			continue
+		}
+
+		split := Order{CreatedAt: order.CreatedAt, SubmittedAt: int(time.Now().Unix()), SupplierId: supplierId, CostCenter: order.CostCenter}
+		if err := db.Create(&split).Error; err != nil {
+			return nil, err
+		}
+
+		for _, line := range linesBySupplier[supplierId] {
+			line.OrderId = split.ID
+			if err := db.Model(&line).UpdateColumn(PurchaseProduct{OrderId: split.ID}).Error; err != nil {
+				return nil, err
+			}
+			split.Pproducts = append(split.Pproducts, line)
+		}
+
+		if err := split.closeIfNoApproval(db, rules); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, split)
+	}
+
+	return orders, nil
+}
+
+// UpdateCostCenter sets the cost center whose budgets the order is charged to
+func (order *Order) UpdateCostCenter(db *gorm.DB, costCenter string) error {
+	if err := order.retreiveOpen(db); err != nil {
+		return err
+	}
+
+	order.CostCenter = costCenter
+	return db.Model(order).UpdateColumn("cost_center", costCenter).Error
+}
+
+// Reject closes the order without creating a Purchase. A comment is mandatory, and only
+// a role required by the rules to approve the submitted order may reject it
+func (order *Order) Reject(db *gorm.DB, rules ApprovalRules, decision *OrderApproval) error {
+	if decision.Comment == "" {
+		return errors.New("[ERROR] A comment must be informed when rejecting an order")
+	}
+
+	if decision.Approver == "" {
+		return errors.New("[ERROR] Approver must be informed")
+	}
+
+	if err := order.retreiveOpen(db); err != nil {
+		return err
+	}
+
+	if order.SubmittedAt == 0 {
+		return errors.New("[ERROR] Order must be submitted before being rejected")
+	}
+
+	required, err := order.requiredRoles(db, rules)
+	if err != nil {
+		return err
+	}
+
+	if !containsRole(required, decision.Role) {
+		return errors.New("[ERROR] Role '" + decision.Role + "' is not required to approve this order")
+	}
+
+	tx := db.Begin()
+	decision.OrderId = order.ID
+	decision.Approved = false
+	if err := decision.Save(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	order.Rejected = true
+	order.ClosedAt = int(time.Now().Unix())
+	if err := tx.Model(order).UpdateColumn(Order{Rejected: true, ClosedAt: order.ClosedAt}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	order.Approvals = append(order.Approvals, *decision)
+
+	return tx.Commit().Error
 }
 
 func (order *Order) Cancel(db *gorm.DB) error {
 	return db.Model(order).UpdateColumn(Order{Canceled: true, ClosedAt: int(time.Now().Unix())}).Error
 }
 
+// retreiveOpen loads the order and verifies that it still accepts decisions
+func (order *Order) retreiveOpen(db *gorm.DB) error {
+	orders, err := (&Order{ID: order.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(orders) != 1 {
+		return errors.New("record not found")
+	}
+
+	if orders[0].Approved || orders[0].Canceled || orders[0].Rejected {
+		return errors.New("[ERROR] Order is already closed")
+	}
+
+	*order = orders[0]
+	return nil
+}
+
+// closeIfNoApproval approves a submitted order right away when the rules don't require any approver
+func (order *Order) closeIfNoApproval(db *gorm.DB, rules ApprovalRules) error {
+	required, err := order.requiredRoles(db, rules)
+	if err != nil {
+		return err
+	}
+
+	if len(required) == 0 {
+		return order.close(db)
+	}
+	return nil
+}
+
+func (order *Order) submit(db *gorm.DB) error {
+	order.SubmittedAt = int(time.Now().Unix())
+	return db.Model(order).UpdateColumn(Order{SubmittedAt: order.SubmittedAt}).Error
+}
+
+// close approves the order and creates the Purchase that comes from it.
+// Lines removed by a buyer are dropped before the Purchase is created
+func (order *Order) close(db *gorm.DB) error {
+	order.Approved = true
+	order.ClosedAt = int(time.Now().Unix())
+	if err := db.Model(order).UpdateColumn(Order{Approved: true, ClosedAt: order.ClosedAt}).Error; err != nil {
+		return err
+	}
+
+	if err := db.Where("order_id = ? and quantity = ?", order.ID, 0).Delete(PurchaseProduct{}).Error; err != nil {
+		return err
+	}
+
+	pproducts := []PurchaseProduct{}
+	for _, pp := range order.Pproducts {
+		if pp.Quantity != 0 {
+			pproducts = append(pproducts, pp)
+		}
+	}
+	order.Pproducts = pproducts
+
+	return NewPurchaseFromOrder(order).Save(db)
+}
+
 // Delete order from database
 func (order *Order) Delete(db *gorm.DB) error {
 	return db.Delete(order).Error
@@ -104,6 +418,11 @@ func (order *Order) AddProduct(db *gorm.DB, pproduct *PurchaseProduct) error {
 		return err
 	}
 
+	if len(pps) == 1 && pps[0].Manual && !pproduct.Manual {
+		fmt.Println("[INFO] Purchase product was edited manually, it won't be overwritten")
+		return nil
+	}
+
 	fmt.Println("[INFO] Inside AddProduct....1")
 	pproduct.OrderId = order.ID
 	if len(pps) == 0 {
@@ -135,6 +454,113 @@ func (order *Order) RemoveProduct(db *gorm.DB, pproduct PurchaseProduct) error {
 	return db.Where(&pproduct).Delete(&pproduct).Error
 }
 
+// removeAutomaticProduct removes the product from the order unless its line was edited manually
+func (order *Order) removeAutomaticProduct(db *gorm.DB, productId int) error {
+	return db.Where("order_id = ? and product_id = ? and manual = ?", order.ID, productId, false).Delete(PurchaseProduct{}).Error
+}
+
+// AddLine adds a product to a draft order on behalf of a buyer.
+// If the product is already on the order its line is replaced
+func (order *Order) AddLine(db *gorm.DB, pproduct *PurchaseProduct) error {
+	if err := order.retreiveDraft(db); err != nil {
+		return err
+	}
+
+	if pproduct.Quantity <= 0 {
+		return errors.New("[ERROR] Quantity must be greater than 0")
+	}
+
+	p := Product{ID: pproduct.ProductId}
+	products, err := p.Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(products) != 1 {
+		return errors.New("[ERROR] Product not found")
+	}
+
+	pproduct.ID = 0
+	pproduct.Manual = true
+	pproduct.Unit = products[0].PurchaseUnit
+	return order.AddProduct(db, pproduct)
+}
+
+// UpdateLine changes the requested quantity of a product already on a draft order
+func (order *Order) UpdateLine(db *gorm.DB, pproduct *PurchaseProduct) error {
+	if err := order.retreiveDraft(db); err != nil {
+		return err
+	}
+
+	if pproduct.Quantity <= 0 {
+		return errors.New("[ERROR] Quantity must be greater than 0")
+	}
+
+	line, err := order.line(pproduct.ProductId)
+	if err != nil {
+		return err
+	}
+
+	line.Quantity = pproduct.Quantity
+	if pproduct.Value != 0 {
+		line.Value = pproduct.Value
+	}
+	line.Manual = true
+
+	*pproduct = *line
+	return db.Save(pproduct).Error
+}
+
+// RemoveLine removes a product from a draft order. Lines created by the automatic
+// refill are kept with quantity 0 so they are not added again until the order is closed
+func (order *Order) RemoveLine(db *gorm.DB, productId int) error {
+	if err := order.retreiveDraft(db); err != nil {
+		return err
+	}
+
+	line, err := order.line(productId)
+	if err != nil {
+		return err
+	}
+
+	line.Quantity = 0
+	line.Value = 0
+	line.Manual = true
+	return db.Save(line).Error
+}
+
+// lineById returns the order's purchase product with the given id
+func (order *Order) lineById(purchaseProductId int) (*PurchaseProduct, error) {
+	for i := range order.Pproducts {
+		if order.Pproducts[i].ID == purchaseProductId {
+			return &order.Pproducts[i], nil
+		}
+	}
+	return nil, errors.New("[ERROR] Purchase product is not on the order")
+}
+
+// line returns the order's purchase product for the given product
+func (order *Order) line(productId int) (*PurchaseProduct, error) {
+	for i := range order.Pproducts {
+		if order.Pproducts[i].ProductId == productId {
+			return &order.Pproducts[i], nil
+		}
+	}
+	return nil, errors.New("[ERROR] Product is not on the order")
+}
+
+// retreiveDraft loads the order and verifies that its lines can still be changed
+func (order *Order) retreiveDraft(db *gorm.DB) error {
+	if err := order.retreiveOpen(db); err != nil {
+		return err
+	}
+
+	if order.SubmittedAt != 0 {
+		return errors.New("[ERROR] Order was already submitted")
+	}
+	return nil
+}
+
 // createAndAddProduct will create a new order an insert the given product in it
 func (order *Order) createAndAddProduct(db *gorm.DB, pproduct *PurchaseProduct) error {
 	fmt.Println("[DEBUG] WILL CREATE NEW ORDER BEFORE INSERTING")
@@ -152,10 +578,11 @@ func (order *Order) createAndAddProduct(db *gorm.DB, pproduct *PurchaseProduct)
 //////////////////////////////////////////////////////////////////////////////////
 //////////////////////////////////////////////////////////////////////////////////
 
-//GetOpenOrder returns an open order if there is one on database
+// GetOpenOrder returns an open order if there is one on database.
+// Submitted orders are waiting for approval and don't accept new products
 func GetOpenOrder(db *gorm.DB) (*Order, error) {
 	order := Order{}
-	if err := db.Where("approved = ?", false).First(&order).Error; err != nil {
+	if err := db.Where("approved = ? and canceled = ? and rejected = ? and submitted_at = ?", false, false, false, 0).First(&order).Error; err != nil {
 		fmt.Println("[ERROR] ", err.Error())
 		return nil, err
 	}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	RoleManager = "manager"
+	RoleFinance = "finance"
+)
+
+// OrderApproval records the decision taken by one approver over an order
+type OrderApproval struct {
+	ID        int    `json:"id"`
+	OrderId   int    `json:"order_id"`
+	Approver  string `json:"approver" sql:"size:255"`
+	Role      string `json:"role" sql:"size:255"`
+	Approved  bool   `json:"approved"`
+	Comment   string `json:"comment" sql:"size:255"`
+	DecidedAt int    `json:"decided_at"`
+}
+
+// ApprovalLevel requires the given role to approve orders whose value reaches Threshold
+type ApprovalLevel struct {
+	Threshold float64
+	Role      string
+}
+
+// ApprovalRules is the set of levels an order goes through before being approved.
+// Orders below every threshold are auto-approved, unless they exceed a budget
+type ApprovalRules struct {
+	Levels []ApprovalLevel
+	Budget BudgetPolicy
+}
+
+// RequiredRoles returns the roles that must approve an order with the given value
+func (rules ApprovalRules) RequiredRoles(value float64) []string {
+	roles := []string{}
+	for _, level := range rules.Levels {
+		if value >= level.Threshold && !containsRole(roles, level.Role) {
+			roles = append(roles, level.Role)
+		}
+	}
+	return roles
+}
+
+// Save approval decision on database
+func (oa *OrderApproval) Save(db *gorm.DB) error {
+	oa.DecidedAt = int(time.Now().Unix())
+	return db.Create(oa).Error
+}
+
+// Retreive approval decisions from database
+func (oa *OrderApproval) Retreive(db *gorm.DB) ([]OrderApproval, error) {
+	var approvals []OrderApproval
+	err := db.Where(*oa).Order("decided_at").Find(&approvals).Error
+	return approvals, err
+}
+
+// requiredRoles returns the roles that must approve the order, escalating it when it exceeds a budget.
+// If the policy is to block, an error is returned instead
+func (order *Order) requiredRoles(db *gorm.DB, rules ApprovalRules) ([]string, error) {
+	roles := rules.RequiredRoles(order.TotalValue())
+
+	exceeded, err := order.exceededBudgets(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(exceeded) == 0 {
+		return roles, nil
+	}
+
+	if rules.Budget.OverBudget != OverBudgetEscalate {
+		return nil, errors.New("[ERROR] Order exceeds the budget of period " + exceeded[0].Period)
+	}
+
+	if !containsRole(roles, rules.Budget.EscalationRole) {
+		roles = append(roles, rules.Budget.EscalationRole)
+	}
+	return roles, nil
+}
+
+// validate checks if the decision can be taken over an order that requires the given roles.
+// Each role must be approved by a different approver
+func (oa *OrderApproval) validate(required []string, decisions []OrderApproval) error {
+	if oa.Approver == "" {
+		return errors.New("[ERROR] Approver must be informed")
+	}
+
+	if !containsRole(required, oa.Role) {
+		return errors.New("[ERROR] Role '" + oa.Role + "' is not required to approve this order")
+	}
+
+	for _, d := range decisions {
+		if d.Role == oa.Role && d.Approved {
+			return errors.New("[ERROR] Order was already approved by role '" + oa.Role + "'")
+		}
+
+		if d.Approver == oa.Approver && d.Approved {
+			return errors.New("[ERROR] " + oa.Approver + " already approved this order as '" + d.Role + "', each role needs a different approver")
+		}
+	}
+	return nil
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// allRolesApproved verifies if every required role has an approval among the decisions
+func allRolesApproved(required []string, decisions []OrderApproval) bool {
+	for _, role := range required {
+		approved := false
+		for _, d := range decisions {
+			if d.Role == role && d.Approved {
+				approved = true
+				break
+			}
+		}
+
+		if !approved {
+			return false
+		}
+	}
+	return true
+}
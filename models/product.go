@@ -7,45 +7,149 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-//Product struct that defines a product
+//Product struct that defines a product. A kit is made of its components, its current
+//quantity is the kits already assembled plus the ones its components' stock can make.
+//Only the components are refilled. Quantities are kept in the base unit, purchases are
+//made in the purchasing unit and withdrawals in the dispensing unit. Every movement of a
+//controlled product is written to the register. The current quantity is the available stock,
+//stock in quarantine, rejected or damaged isn't part of it
 type Product struct {
-	ID            int               `json:"id"`
-	Name          string            `json:"name" sql:"size:255"`
-	Type          int               `json:"type"`
-	Description   string            `json:"description" sql:"size:255"`
-	CurrQuantity  int               `json:"curr_quantity"`
-	MinQuantity   int               `json:"min_quantity"`
-	PurchProducts []PurchaseProduct `json:"purchase_products"`
-	Withdrawals   []Withdrawal      `json:"withdrawals"`
-	CurrentValue  float64           `json:"current_value"`
+	ID                    int               `json:"id"`
+	Name                  string            `json:"name" sql:"size:255"`
+	Type                  int               `json:"type"`
+	Description           string            `json:"description" sql:"size:255"`
+	CurrQuantity          int               `json:"curr_quantity"`
+	MinQuantity           int               `json:"min_quantity"`
+	PurchProducts         []PurchaseProduct `json:"purchase_products"`
+	Withdrawals           []Withdrawal      `json:"withdrawals"`
+	CurrentValue          float64           `json:"current_value"`
+	IsKit                 bool              `json:"is_kit"`
+	AssembledQuantity     int               `json:"assembled_quantity"`
+	Components            []KitComponent    `json:"components"`
+	BaseUnit              string            `json:"base_unit" sql:"size:255"`
+	PurchaseUnit          string            `json:"purchase_unit" sql:"size:255"`
+	DispenseUnit          string            `json:"dispense_unit" sql:"size:255"`
+	Units                 []UnitOfMeasure   `json:"units"`
+	Controlled            bool              `json:"controlled"`
+	QuarantinedQuantity   int               `json:"quarantined_quantity"`
+	RejectedQuantity      int               `json:"rejected_quantity"`
+	DamagedQuantity       int               `json:"damaged_quantity"`
+	MinShelfLifeDays      int               `json:"min_shelf_life_days"`
+	Serialized            bool              `json:"serialized"`
+	TemperatureSensitive  bool              `json:"temperature_sensitive"`
+	StorageMinTemperature float64           `json:"storage_min_temperature"`
+	StorageMaxTemperature float64           `json:"storage_max_temperature"`
 }
 
 //Save new product on database
 func (p *Product) Save(db *gorm.DB) error {
-	if err := db.Create(p).Error; err != nil {
+	if err := p.validateKit(db); err != nil {
 		return err
 	}
 
+	if err := p.validateUnits(); err != nil {
+		return err
+	}
+
+	if err := p.validateStorage(); err != nil {
+		return err
+	}
+
+	if p.IsKit {
+		p.CurrQuantity = 0
+	}
+
+	tx := db.Begin()
+	if err := tx.Create(p).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if p.Controlled && p.CurrQuantity != 0 {
+		if err := registerMovement(tx, p, MovementOpening, p.CurrQuantity, "", Signoff{}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
 	fmt.Println("[INFO] Product saved..")
+
+	if p.IsKit {
+		fmt.Println("[INFO] Kits are refilled through their components")
+		return p.loadAvailability(db)
+	}
+
 	fmt.Println("[INFO] Will verify if refill is needed")
 
 	if p.CurrQuantity < p.MinQuantity {
 		fmt.Println("[INFO] It does need refill")
-		pp := NewPurchaseProduct(p)
+		pp, err := NewPurchaseProduct(p)
+		if err != nil {
+			return err
+		}
 		return AddProductToOpenOrder(db, pp)
 	}
 
 	return nil
 }
 
-// Update product on database
+// Update product on database. A product that stops being a kit loses its components.
+// The quantities in quarantine, rejected, damaged and assembled are kept by the warehouse
+// and aren't changed
 func (p *Product) Update(db *gorm.DB) error {
+	if err := p.validateKit(db); err != nil {
+		return err
+	}
+
+	if err := p.validateStorage(); err != nil {
+		return err
+	}
+
+	if err := p.loadCounters(db); err != nil {
+		return err
+	}
+
+	//units not informed are kept, the purchasing and dispensing units must still be among them
+	if err := p.loadUnits(db); err != nil {
+		return err
+	}
+
+	if err := p.validateUnits(); err != nil {
+		return err
+	}
+
+	if err := db.Where("product_id = ?", p.ID).Delete(UnitOfMeasure{}).Error; err != nil {
+		return err
+	}
+
+	if p.IsKit {
+		p.CurrQuantity = 0
+		if err := db.Where("product_id = ?", p.ID).Delete(KitComponent{}).Error; err != nil {
+			return err
+		}
+
+		if err := db.Save(p).Error; err != nil {
+			return err
+		}
+		return p.loadAvailability(db)
+	}
+
+	if err := db.Where("product_id = ?", p.ID).Delete(KitComponent{}).Error; err != nil {
+		return err
+	}
+
 	if err := db.Save(p).Error; err != nil {
 		return err
 	}
 
 	if p.CurrQuantity < p.MinQuantity {
-		pp := NewPurchaseProduct(p)
+		pp, err := NewPurchaseProduct(p)
+		if err != nil {
+			return err
+		}
 		return AddProductToOpenOrder(db, pp)
 	} else {
 		pp := &PurchaseProduct{ProductId: p.ID}
@@ -54,45 +158,113 @@ func (p *Product) Update(db *gorm.DB) error {
 			return err
 		}
 		if order != nil {
-			pp.OrderId = order.ID
-			order.RemoveProduct(db, *pp)
+			return order.removeAutomaticProduct(db, p.ID)
 		}
 	}
 	return nil
 }
 
-// Delete product on database
+//loadCounters reads the quantities kept by the warehouse itself, so saving the product
+//doesn't overwrite them
+func (p *Product) loadCounters(db *gorm.DB) error {
+	curr := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&curr).Error; err != nil {
+		return err
+	}
+
+	p.AssembledQuantity = curr.AssembledQuantity
+	p.QuarantinedQuantity = curr.QuarantinedQuantity
+	p.RejectedQuantity = curr.RejectedQuantity
+	p.DamagedQuantity = curr.DamagedQuantity
+	return nil
+}
+
+// Delete product on database, along with its components when it's a kit.
+// Products that are components of a kit can't be deleted
 func (p *Product) Delete(db *gorm.DB) error {
-	return db.Where(p).Delete(Product{}).Error
+	kits := []KitComponent{}
+	if err := db.Where(KitComponent{ComponentId: p.ID}).Find(&kits).Error; err != nil {
+		return err
+	}
+
+	if len(kits) != 0 {
+		return errors.New("[ERROR] Product is a component of a kit, remove it from the kit first")
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("product_id = ?", p.ID).Delete(KitComponent{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where(p).Delete(Product{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
 }
 
 // Retreive product on database
 func (p *Product) Retreive(db *gorm.DB) ([]Product, error) {
 	var products []Product
-	err := db.Where(*p).Find(&products).Error
+	if err := db.Where(*p).Find(&products).Error; err != nil {
+		return nil, err
+	}
 
-	return products, err
+	for i := range products {
+		if err := products[i].loadAvailability(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return products, nil
 }
 
 // Consume product using the id provided and quantity
 // if issued quantity > current quantity an error will be returned
+// consuming a kit withdraws its components
 func (p *Product) Consume(db *gorm.DB, quantity int) error {
+	return p.ConsumeFor(db, quantity, Withdrawal{})
+}
+
+//ConsumeFor consumes the product like Consume, recording on the withdrawal the requester,
+//department and patient reference of the recipient. Serialized products are only consumed
+//with the serial numbers of the units issued
+func (p *Product) ConsumeFor(db *gorm.DB, quantity int, recipient Withdrawal) error {
 	var pp Product
 
 	if err := db.Where(*p).First(&pp).Error; err != nil {
 		return err
 	}
 
-	if pp.CurrQuantity-quantity < 0 {
-		return errors.New("Requested quantity exceeds the available amount")
+	witness, err := pp.requiresWitness(db)
+	if err != nil {
+		return err
 	}
 
-	pp.CurrQuantity = pp.CurrQuantity - quantity
+	if witness {
+		return errWitnessRequired
+	}
 
-	if err := pp.Update(db); err != nil {
-		return err
+	if pp.IsKit {
+		return pp.consumeKit(db, quantity, recipient, Signoff{})
 	}
 
+	tx := db.Begin()
 	w := NewWithdrawl(pp, quantity)
-	return w.Save(db)
+	w.issuedTo(recipient)
+	if err := pp.issue(tx, w, 0, Signoff{}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if pp.Serialized {
+		if err := withdrawSerials(tx, w, recipient.Serials); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
 }
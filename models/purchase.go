@@ -3,28 +3,32 @@ package models
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
 type Purchase struct {
-	ID             int     `json:"id"`
-	CreatedAt      int     `json:"created_at"`
-	ConfirmedAt    int     `json:"confirmed_at"`
-	ConcludedAt    int     `json:"concluded_at"`
-	TotalValue     float64 `json:"total_value"`
-	PurschaseOrder Order   `json:"order"`
-	OrderId        int     `json:"order_id"`
+	ID               int     `json:"id"`
+	CreatedAt        int     `json:"created_at"`
+	ConfirmedAt      int     `json:"confirmed_at"`
+	ConcludedAt      int     `json:"concluded_at"`
+	ExpectedAt       int     `json:"expected_at"`
+	LateNotifiedAt   int     `json:"late_notified_at"`
+	RejectedAt       int     `json:"rejected_at"`
+	RejectionComment string  `json:"rejection_comment" sql:"size:255"`
+	TotalValue       float64 `json:"total_value"`
+	CreditedValue    float64 `json:"credited_value"`
+	PayableValue     float64 `json:"payable_value" sql:"-"`
+	PurschaseOrder   Order   `json:"order"`
+	OrderId          int     `json:"order_id"`
+	SupplierId       int     `json:"supplier_id"`
 }
 
 // NewPurchaseFromOrder return a pointer to a newly created struct that uses an order as parameter
 func NewPurchaseFromOrder(o *Order) *Purchase {
-	totalValue := 0.0
-	for _, pp := range o.Pproducts {
-		totalValue += pp.Value
-	}
-	return &Purchase{CreatedAt: int(time.Now().Unix()), TotalValue: totalValue, OrderId: o.ID}
+	return &Purchase{CreatedAt: int(time.Now().Unix()), TotalValue: o.TotalValue(), OrderId: o.ID, SupplierId: o.SupplierId}
 }
 
 // Retreive purchase from database
@@ -51,6 +55,7 @@ func (purch *Purchase) Retreive(db *gorm.DB) ([]Purchase, error) {
 		order.Pproducts = pproducts
 
 		p.PurschaseOrder = order
+		p.PayableValue = p.TotalValue - p.CreditedValue
 		purchs[i] = p
 	}
 
@@ -72,9 +77,83 @@ func (purch *Purchase) Delete(db *gorm.DB) error {
 	return db.Where(purch).Delete(Purchase{}).Error
 }
 
-// Confirm purchase
-func (purch *Purchase) Confirm(db *gorm.DB) error {
-	return db.Model(purch).UpdateColumn(Purchase{ConfirmedAt: int(time.Now().Unix())}).Error
+// Confirm purchase with the delivery date promised by the supplier.
+// If it's not informed, the supplier's lead time is used to compute it.
+// Purchases already confirmed, concluded or rejected can't be confirmed
+func (purch *Purchase) Confirm(db *gorm.DB, expectedAt int) error {
+	p, err := retreiveSinglePurchase(db, purch.ID)
+	if err != nil {
+		return err
+	}
+	*purch = *p
+
+	if purch.ConfirmedAt != 0 || purch.ConcludedAt != 0 {
+		return errors.New("[ERROR] Purchase was already confirmed")
+	}
+
+	if purch.RejectedAt != 0 {
+		return errors.New("[ERROR] Purchase was rejected by the supplier")
+	}
+
+	purch.ConfirmedAt = int(time.Now().Unix())
+	purch.ExpectedAt = expectedAt
+	if purch.ExpectedAt == 0 && purch.SupplierId != 0 {
+		supplier := Supplier{}
+		if err := db.Where(Supplier{ID: purch.SupplierId}).First(&supplier).Error; err != nil {
+			return err
+		}
+
+		if supplier.LeadTimeDays > 0 {
+			purch.ExpectedAt = purch.ConfirmedAt + supplier.LeadTimeDays*24*60*60
+		}
+	}
+
+	return db.Model(purch).UpdateColumns(Purchase{ConfirmedAt: purch.ConfirmedAt, ExpectedAt: purch.ExpectedAt}).Error
+}
+
+// ApplyDespatch registers the quantities the supplier announced as shipped, by purchase product id.
+// The delivery date announced replaces the expected one
+func (purch *Purchase) ApplyDespatch(db *gorm.DB, despatched map[int]int, deliveryAt int) error {
+	p, err := retreiveSinglePurchase(db, purch.ID)
+	if err != nil {
+		return err
+	}
+	*purch = *p
+
+	if purch.ConfirmedAt == 0 || purch.ConcludedAt != 0 {
+		return errors.New("[ERROR] Only confirmed purchases can be despatched")
+	}
+
+	tx := db.Begin()
+	for id, quantity := range despatched {
+		pp := findPurchaseProduct(purch.PurschaseOrder.Pproducts, id)
+		if pp == nil {
+			tx.Rollback()
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+
+		pp.DespatchedQuantity += quantity
+		if err := tx.Model(pp).UpdateColumn(PurchaseProduct{DespatchedQuantity: pp.DespatchedQuantity}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if deliveryAt != 0 {
+		purch.ExpectedAt = deliveryAt
+		if err := tx.Model(purch).UpdateColumn(Purchase{ExpectedAt: deliveryAt}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// NotifyLate marks that the late delivery of the purchase was already notified
+func (purch *Purchase) NotifyLate(db *gorm.DB) error {
+	purch.LateNotifiedAt = int(time.Now().Unix())
+	return db.Model(purch).UpdateColumn(Purchase{LateNotifiedAt: purch.LateNotifiedAt}).Error
 }
 
 // Conclude purchase
@@ -99,8 +178,30 @@ func (purch *Purchase) RetreiveConcluded(db *gorm.DB) ([]Purchase, error) {
 	return purch.retreivePlainQuery(db, "confirmed_at != 0 and concluded_at != 0")
 }
 
+// RetreiveLate returns the purchases not concluded nor rejected after the promised delivery date
+func (purch *Purchase) RetreiveLate(db *gorm.DB) ([]Purchase, error) {
+	return purch.retreivePlainQuery(db, "concluded_at = 0 and rejected_at = 0 and expected_at != 0 and expected_at < "+strconv.FormatInt(time.Now().Unix(), 10))
+}
+
 func (purch *Purchase) retreivePlainQuery(db *gorm.DB, query string) ([]Purchase, error) {
 	purchs := []Purchase{}
 	err := db.Where(query).Find(&purchs).Error
+	for i, p := range purchs {
+		purchs[i].PayableValue = p.TotalValue - p.CreditedValue
+	}
 	return purchs, err
 }
+
+// retreiveSinglePurchase returns the purchase with the given id along with its order and purchase products
+func retreiveSinglePurchase(db *gorm.DB, id int) (*Purchase, error) {
+	purchs, err := (&Purchase{ID: id}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(purchs) != 1 {
+		return nil, errors.New("record not found")
+	}
+
+	return &purchs[0], nil
+}
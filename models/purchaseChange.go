@@ -0,0 +1,251 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ChangeProposed = "proposed"
+	ChangeAccepted = "accepted"
+	ChangeRejected = "rejected"
+)
+
+// PurchaseChange is a change proposed by the supplier that the buyer must accept or reject.
+// When PurchaseProductId is 0 the change refers to the whole purchase
+type PurchaseChange struct {
+	ID                int    `json:"id"`
+	PurchaseId        int    `json:"purchase_id"`
+	PurchaseProductId int    `json:"purchase_product_id"`
+	Quantity          int    `json:"quantity"`
+	ExpectedAt        int    `json:"expected_at"`
+	Rejected          bool   `json:"rejected"`
+	Comment           string `json:"comment" sql:"size:255"`
+	Status            string `json:"status" sql:"size:255"`
+	ProposedAt        int    `json:"proposed_at"`
+	DecidedAt         int    `json:"decided_at"`
+}
+
+// SupplierResponse is what the supplier answers through the purchase link.
+// Lines are only needed when the supplier doesn't accept the purchase as it is
+type SupplierResponse struct {
+	ExpectedAt int              `json:"expected_at"`
+	Comment    string           `json:"comment"`
+	Lines      []PurchaseChange `json:"lines"`
+}
+
+// Retreive changes from database
+func (pc *PurchaseChange) Retreive(db *gorm.DB) ([]PurchaseChange, error) {
+	var changes []PurchaseChange
+	err := db.Where(*pc).Find(&changes).Error
+	return changes, err
+}
+
+// SupplierConfirm confirms the purchase when the supplier accepts it as it is.
+// Line changes, including rejected lines, are kept as proposals and the purchase
+// is only confirmed once the buyer accepts them
+func (purch *Purchase) SupplierConfirm(db *gorm.DB, response *SupplierResponse) error {
+	if err := purch.retreiveAwaitingSupplier(db); err != nil {
+		return err
+	}
+
+	if len(response.Lines) == 0 {
+		return purch.Confirm(db, response.ExpectedAt)
+	}
+
+	for _, line := range response.Lines {
+		if line.Quantity <= 0 && !line.Rejected {
+			return errors.New("[ERROR] Quantity must be greater than 0, reject the line instead")
+		}
+	}
+
+	return purch.proposeChanges(db, response, false)
+}
+
+// SupplierReject rejects the whole purchase or, when lines are informed, only those lines
+func (purch *Purchase) SupplierReject(db *gorm.DB, response *SupplierResponse) error {
+	if err := purch.retreiveAwaitingSupplier(db); err != nil {
+		return err
+	}
+
+	if response.Comment == "" {
+		return errors.New("[ERROR] A comment must be informed when rejecting a purchase")
+	}
+
+	if len(response.Lines) == 0 {
+		purch.RejectedAt = int(time.Now().Unix())
+		purch.RejectionComment = response.Comment
+		return db.Model(purch).UpdateColumns(Purchase{RejectedAt: purch.RejectedAt, RejectionComment: purch.RejectionComment}).Error
+	}
+
+	return purch.proposeChanges(db, response, true)
+}
+
+// proposeChanges replaces the pending proposals of the purchase by the supplier's response
+func (purch *Purchase) proposeChanges(db *gorm.DB, response *SupplierResponse, rejected bool) error {
+	now := int(time.Now().Unix())
+	changes := []PurchaseChange{}
+	for _, line := range response.Lines {
+		pp := findPurchaseProduct(purch.PurschaseOrder.Pproducts, line.PurchaseProductId)
+		if pp == nil {
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+
+		change := PurchaseChange{PurchaseId: purch.ID, PurchaseProductId: pp.ID, Quantity: line.Quantity, ExpectedAt: line.ExpectedAt, Comment: response.Comment, Rejected: rejected || line.Rejected, Status: ChangeProposed, ProposedAt: now}
+		if change.Rejected {
+			change.Quantity = 0
+		}
+		changes = append(changes, change)
+	}
+
+	if response.ExpectedAt != 0 {
+		changes = append(changes, PurchaseChange{PurchaseId: purch.ID, ExpectedAt: response.ExpectedAt, Comment: response.Comment, Status: ChangeProposed, ProposedAt: now})
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("purchase_id = ? and status = ?", purch.ID, ChangeProposed).Delete(PurchaseChange{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range changes {
+		if err := tx.Create(&changes[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Accept applies the change to the purchase. The purchase is confirmed once no proposal is pending
+func (pc *PurchaseChange) Accept(db *gorm.DB) error {
+	purch, err := pc.retreiveProposed(db)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if pc.PurchaseProductId != 0 {
+		pp := findPurchaseProduct(purch.PurschaseOrder.Pproducts, pc.PurchaseProductId)
+		if pp == nil {
+			tx.Rollback()
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+
+		unitValue := pp.UnitValue()
+		if err := tx.Model(pp).UpdateColumns(map[string]interface{}{"quantity": pc.Quantity, "value": unitValue * float64(pc.Quantity)}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		purch.TotalValue += unitValue*float64(pc.Quantity) - pp.Value
+		if err := tx.Model(purch).UpdateColumn("total_value", purch.TotalValue).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := pc.decide(tx, ChangeAccepted); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	return purch.confirmIfNoProposal(db)
+}
+
+// Reject refuses the change. The supplier can answer again through the purchase link
+func (pc *PurchaseChange) Reject(db *gorm.DB) error {
+	if _, err := pc.retreiveProposed(db); err != nil {
+		return err
+	}
+	return pc.decide(db, ChangeRejected)
+}
+
+func (pc *PurchaseChange) decide(db *gorm.DB, status string) error {
+	pc.Status = status
+	pc.DecidedAt = int(time.Now().Unix())
+	return db.Model(pc).UpdateColumns(PurchaseChange{Status: pc.Status, DecidedAt: pc.DecidedAt}).Error
+}
+
+// retreiveProposed loads the change, verifying that it's still pending, and its purchase
+func (pc *PurchaseChange) retreiveProposed(db *gorm.DB) (*Purchase, error) {
+	changes, err := (&PurchaseChange{ID: pc.ID}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changes) != 1 {
+		return nil, errors.New("record not found")
+	}
+	*pc = changes[0]
+
+	if pc.Status != ChangeProposed {
+		return nil, errors.New("[ERROR] Change was already " + pc.Status)
+	}
+
+	purch := &Purchase{ID: pc.PurchaseId}
+	if err := purch.retreiveAwaitingSupplier(db); err != nil {
+		return nil, err
+	}
+	return purch, nil
+}
+
+// confirmIfNoProposal confirms the purchase once every change of the supplier's last answer
+// was accepted. The delivery date is the latest one accepted
+func (purch *Purchase) confirmIfNoProposal(db *gorm.DB) error {
+	changes, err := (&PurchaseChange{PurchaseId: purch.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	latest := latestProposal(changes)
+	expectedAt := 0
+	for _, c := range changes {
+		if c.ProposedAt != latest {
+			continue
+		}
+
+		if c.Status != ChangeAccepted {
+			return nil
+		}
+
+		if c.ExpectedAt > expectedAt {
+			expectedAt = c.ExpectedAt
+		}
+	}
+
+	return purch.Confirm(db, expectedAt)
+}
+
+// latestProposal returns when the supplier answered for the last time
+func latestProposal(changes []PurchaseChange) int {
+	latest := 0
+	for _, c := range changes {
+		if c.ProposedAt > latest {
+			latest = c.ProposedAt
+		}
+	}
+	return latest
+}
+
+// retreiveAwaitingSupplier loads the purchase and verifies that it's waiting for the supplier's answer
+func (purch *Purchase) retreiveAwaitingSupplier(db *gorm.DB) error {
+	p, err := retreiveSinglePurchase(db, purch.ID)
+	if err != nil {
+		return err
+	}
+
+	if p.ConfirmedAt != 0 || p.RejectedAt != 0 {
+		return errors.New("[ERROR] Purchase was already answered by the supplier")
+	}
+
+	*purch = *p
+	return nil
+}
@@ -7,15 +7,36 @@ import (
 )
 
 type PurchaseProduct struct {
-	ID        int     `json:"id"`
-	Value     float64 `json:"value"`
-	Quantity  int     `json:"quantity"`
-	ProductId int     `json:"product_id"`
-	OrderId   int     `json:"order_id"`
+	ID                 int     `json:"id"`
+	Value              float64 `json:"value"`
+	Quantity           int     `json:"quantity"`
+	ProductId          int     `json:"product_id"`
+	OrderId            int     `json:"order_id"`
+	Manual             bool    `json:"manual"`
+	SupplierId         int     `json:"supplier_id"`
+	ReceivedQuantity   int     `json:"received_quantity"`
+	ReturnedQuantity   int     `json:"returned_quantity"`
+	DespatchedQuantity int     `json:"despatched_quantity"`
+	Unit               string  `json:"unit" sql:"size:255"`
 }
 
-func NewPurchaseProduct(p *Product) *PurchaseProduct {
-	return &PurchaseProduct{Quantity: p.MinQuantity - p.CurrQuantity, ProductId: p.ID}
+// NewPurchaseProduct returns the purchase of the quantity missing to reach the minimum, rounded up to the purchasing unit
+func NewPurchaseProduct(p *Product) (*PurchaseProduct, error) {
+	factor, err := p.Factor(p.PurchaseUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	quantity := (p.MinQuantity - p.CurrQuantity + factor - 1) / factor
+	return &PurchaseProduct{Quantity: quantity, ProductId: p.ID, Unit: p.PurchaseUnit}, nil
+}
+
+// UnitValue returns the value of a single unit, as Value is the value of the whole line
+func (pp *PurchaseProduct) UnitValue() float64 {
+	if pp.Quantity == 0 {
+		return 0
+	}
+	return pp.Value / float64(pp.Quantity)
 }
 
 func VerifyUpdatePurchaseProduct(db *gorm.DB, pp *PurchaseProduct) error {
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Recall is a manufacturer's recall of lots of a product. Recalled lots can't be withdrawn and
+// their remaining stock is moved to quarantine. The lots produced from them by work orders are
+// recalled with them
+type Recall struct {
+	ID        int         `json:"id"`
+	ProductId int         `json:"product_id"`
+	Reason    string      `json:"reason" sql:"size:255"`
+	Reference string      `json:"reference" sql:"size:255"`
+	CreatedAt int         `json:"created_at"`
+	Lots      []RecallLot `json:"lots"`
+}
+
+// RecallLot is a lot of the recall and the quantity of it quarantined when it was recalled.
+// SourceLotId is the recalled lot a work order produced it from, 0 for the lots recalled directly
+type RecallLot struct {
+	ID                  int    `json:"id"`
+	RecallId            int    `json:"recall_id"`
+	ProductId           int    `json:"product_id"`
+	LotId               int    `json:"lot_id"`
+	LotNumber           string `json:"lot_number" sql:"size:255"`
+	SourceLotId         int    `json:"source_lot_id"`
+	QuarantinedQuantity int    `json:"quarantined_quantity"`
+}
+
+// RecallTrace lists every withdrawal of the recalled lots, so the ones who received them can be contacted
+type RecallTrace struct {
+	Recall      Recall             `json:"recall"`
+	Withdrawals []TracedWithdrawal `json:"withdrawals"`
+}
+
+// TracedWithdrawal is the quantity of a recalled lot issued by a withdrawal. Components of a kit
+// hold the withdrawal of the kit they were issued with
+type TracedWithdrawal struct {
+	WithdrawalId       int    `json:"withdrawal_id"`
+	ProductId          int    `json:"product_id"`
+	LotNumber          string `json:"lot_number"`
+	Quantity           int    `json:"quantity"`
+	IssuedAt           int    `json:"issued_at"`
+	Requester          string `json:"requester"`
+	Department         string `json:"department"`
+	PatientRef         string `json:"patient_ref"`
+	RequisitionId      int    `json:"requisition_id"`
+	KitId              int    `json:"kit_id"`
+	ParentWithdrawalId int    `json:"parent_withdrawal_id"`
+}
+
+// Save new recall on database, recalling its lots and the lots produced from them and quarantining
+// their stock in one transaction. Lots still in receiving quarantine are recalled before they are released
+func (r *Recall) Save(db *gorm.DB) error {
+	if len(r.Lots) == 0 {
+		return errors.New("[ERROR] Recall must have at least one lot")
+	}
+
+	if err := db.Where(Product{ID: r.ProductId}).First(&Product{}).Error; err != nil {
+		return err
+	}
+
+	r.ID = 0
+	r.CreatedAt = int(time.Now().Unix())
+
+	tx := db.Begin()
+	for i, rl := range r.Lots {
+		lots := []StockLot{}
+		if err := tx.Where(StockLot{ProductId: r.ProductId, LotNumber: rl.LotNumber}).Find(&lots).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if len(lots) > 1 {
+			tx.Rollback()
+			return errors.New("[ERROR] Lot " + rl.LotNumber + " found more than once for the product")
+		}
+
+		if len(lots) == 0 {
+			lot, err := lotInReceiving(tx, r.ProductId, rl.LotNumber)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			lots = append(lots, *lot)
+		}
+
+		lot := lots[0]
+		if lot.Status == LotRecalled {
+			tx.Rollback()
+			return errors.New("[ERROR] Lot " + rl.LotNumber + " is already recalled")
+		}
+
+		recalled, err := r.recallLot(tx, &lot)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		r.Lots[i] = *recalled
+	}
+
+	derived, err := r.recallDerivedLots(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	r.Lots = append(r.Lots, derived...)
+
+	if err := tx.Create(r).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Retreive recalls from database
+func (r *Recall) Retreive(db *gorm.DB) ([]Recall, error) {
+	var rs []Recall
+	if err := db.Where(*r).Find(&rs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, rc := range rs {
+		lots := []RecallLot{}
+		if err := db.Model(rc).Related(&lots, "Lots").Error; err != nil {
+			return nil, err
+		}
+		rs[i].Lots = lots
+	}
+
+	return rs, nil
+}
+
+// Trace lists the withdrawals of the recalled lots, the oldest first
+func (r *Recall) Trace(db *gorm.DB) (*RecallTrace, error) {
+	rs, err := (&Recall{ID: r.ID}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rs) != 1 {
+		return nil, errors.New("record not found")
+	}
+
+	trace := &RecallTrace{Recall: rs[0], Withdrawals: []TracedWithdrawal{}}
+	lotIds := []int{}
+	for _, rl := range trace.Recall.Lots {
+		lotIds = append(lotIds, rl.LotId)
+	}
+
+	links, err := lotLinksFrom(db, lotIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		if !containsLot(lotIds, link.OutputLotId) {
+			lotIds = append(lotIds, link.OutputLotId)
+		}
+	}
+
+	wls := []WithdrawalLot{}
+	if err := db.Where("lot_id in (?)", lotIds).Order("withdrawal_id").Find(&wls).Error; err != nil {
+		return nil, err
+	}
+
+	for _, wl := range wls {
+		w := Withdrawal{}
+		if err := db.Where("id = ?", wl.WithdrawalId).First(&w).Error; err != nil {
+			return nil, err
+		}
+
+		trace.Withdrawals = append(trace.Withdrawals, TracedWithdrawal{
+			WithdrawalId:       w.ID,
+			ProductId:          w.ProductId,
+			LotNumber:          wl.LotNumber,
+			Quantity:           wl.Quantity,
+			IssuedAt:           w.IssuedAt,
+			Requester:          w.Requester,
+			Department:         w.Department,
+			PatientRef:         w.PatientRef,
+			RequisitionId:      w.RequisitionId,
+			KitId:              w.KitId,
+			ParentWithdrawalId: w.ParentWithdrawalId,
+		})
+	}
+
+	return trace, nil
+}
+
+// recallDerivedLots recalls the lots that work orders produced from the lots of the recall, and
+// the ones produced from those, returning them
+func (r *Recall) recallDerivedLots(db *gorm.DB) ([]RecallLot, error) {
+	lotIds := []int{}
+	for _, rl := range r.Lots {
+		lotIds = append(lotIds, rl.LotId)
+	}
+
+	links, err := lotLinksFrom(db, lotIds)
+	if err != nil {
+		return nil, err
+	}
+
+	derived := []RecallLot{}
+	for _, link := range links {
+		lot := StockLot{}
+		if err := db.Where(StockLot{ID: link.OutputLotId}).First(&lot).Error; err != nil {
+			return nil, err
+		}
+
+		if lot.Status == LotRecalled {
+			continue
+		}
+
+		recalled, err := r.recallLot(db, &lot)
+		if err != nil {
+			return nil, err
+		}
+		recalled.SourceLotId = link.InputLotId
+		derived = append(derived, *recalled)
+	}
+
+	return derived, nil
+}
+
+// recallLot recalls the lot, quarantining its stock. The stock of a lot on hold is already in
+// quarantine, as is the stock of the lot received in quarantine: their pending inspections are
+// canceled, so it can't be released, and their quantity recalled with the lot
+func (r *Recall) recallLot(db *gorm.DB, lot *StockLot) (*RecallLot, error) {
+	if lot.Status == LotQuarantine {
+		lot.Status = LotRecalled
+		if err := db.Model(lot).UpdateColumn("status", lot.Status).Error; err != nil {
+			return nil, err
+		}
+	} else if err := quarantineLot(db, lot, LotRecalled); err != nil {
+		return nil, err
+	}
+
+	recalled := &RecallLot{ProductId: lot.ProductId, LotId: lot.ID, LotNumber: lot.LotNumber, QuarantinedQuantity: lot.Quantity}
+	pending, err := (&Inspection{ProductId: lot.ProductId, LotNumber: lot.LotNumber, Status: InspectionPending}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, in := range pending {
+		if err := in.cancel(db, "lot recalled: "+r.Reason); err != nil {
+			return nil, err
+		}
+
+		if in.LotId == 0 {
+			recalled.QuarantinedQuantity += in.Quantity
+		}
+	}
+
+	return recalled, nil
+}
+
+// lotInReceiving returns a lot for the goods of the lot number still held in receiving quarantine,
+// which only get their lot once released. The lot is created empty and in quarantine, so it can be
+// recalled and later deliveries of the lot number are refused
+func lotInReceiving(db *gorm.DB, productId int, lotNumber string) (*StockLot, error) {
+	pending, err := (&Inspection{ProductId: productId, LotNumber: lotNumber, Status: InspectionPending}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pending) == 0 {
+		return nil, errors.New("[ERROR] Lot " + lotNumber + " not found for the product")
+	}
+
+	lot := &StockLot{ProductId: productId, LotNumber: lotNumber, ExpiresAt: pending[0].ExpiresAt, Location: pending[0].Location, Status: LotQuarantine, CreatedAt: int(time.Now().Unix())}
+	return lot, db.Create(lot).Error
+}
+
+// lotLinksFrom returns the links from the lots to the lots produced from them, following the
+// produced lots into the work orders that used them in turn
+func lotLinksFrom(db *gorm.DB, lotIds []int) ([]LotLink, error) {
+	links := []LotLink{}
+	visited := append([]int{}, lotIds...)
+	for len(lotIds) != 0 {
+		found := []LotLink{}
+		if err := db.Where("input_lot_id in (?)", lotIds).Order("id").Find(&found).Error; err != nil {
+			return nil, err
+		}
+
+		lotIds = []int{}
+		for _, link := range found {
+			if containsLot(visited, link.OutputLotId) {
+				continue
+			}
+			visited = append(visited, link.OutputLotId)
+			lotIds = append(lotIds, link.OutputLotId)
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
+
+func containsLot(lotIds []int, lotId int) bool {
+	for _, id := range lotIds {
+		if id == lotId {
+			return true
+		}
+	}
+	return false
+}
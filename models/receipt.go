@@ -0,0 +1,276 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ShortShelfLifeReject     = "reject"
+	ShortShelfLifeQuarantine = "quarantine"
+)
+
+// ReceivingPolicy tells what's done with goods received with less than the minimum remaining shelf
+// life of their product: rejected, or kept in quarantine until inspected. QuantityTolerance is how
+// far, as a fraction, the quantity received may exceed the quantity ordered
+type ReceivingPolicy struct {
+	ShortShelfLife    string
+	QuantityTolerance float64
+}
+
+// Receipt registers the goods delivered by the supplier of a purchase. Backorders holds the
+// backorders fulfilled with the received stock. Goods received in quarantine aren't available
+// until they are inspected, Inspections holding the inspections pending for them
+type Receipt struct {
+	ID          int           `json:"id"`
+	PurchaseId  int           `json:"purchase_id"`
+	ReceivedAt  int           `json:"received_at"`
+	Quarantine  bool          `json:"quarantine"`
+	Lines       []ReceiptLine `json:"lines"`
+	Backorders  []Backorder   `json:"backorders" sql:"-"`
+	Inspections []Inspection  `json:"inspections" sql:"-"`
+}
+
+// ReceiptLine is the quantity received for one purchase product, in its purchasing unit, and that
+// quantity in the product's base unit. Goods received with a lot number are added to the stock of the lot.
+// Lines with less than the product's minimum remaining shelf life are flagged and either rejected,
+// not counting as received, or held for inspection. Goods held for inspection only count as received
+// once released. Serialized products are received with the serial
+// number of each unit. Lots are stored at the location of the line. Temperature sensitive products
+// must be received with a lot number and a location, so excursions at the location reach them
+type ReceiptLine struct {
+	ID                int      `json:"id"`
+	ReceiptId         int      `json:"receipt_id"`
+	PurchaseProductId int      `json:"purchase_product_id"`
+	ProductId         int      `json:"product_id"`
+	Quantity          int      `json:"quantity"`
+	LotNumber         string   `json:"lot_number" sql:"size:255"`
+	ExpiresAt         int      `json:"expires_at"`
+	LotId             int      `json:"lot_id"`
+	BaseQuantity      int      `json:"base_quantity"`
+	ShortShelfLife    bool     `json:"short_shelf_life"`
+	Rejected          bool     `json:"rejected"`
+	Serials           []string `json:"serials,omitempty" sql:"-"`
+	Location          string   `json:"location" sql:"size:255"`
+}
+
+// Save the receipt, adding the received quantities to the purchase products and to the products stock.
+// The open backorders of the received products, and of the kits they are part of, are then fulfilled
+// following the allocation policy. Goods with a short shelf life are handled as the receiving policy says.
+// Goods beyond the quantity ordered, counting the goods still in quarantine, are refused
+func (r *Receipt) Save(db *gorm.DB, policy AllocationPolicy, receiving ReceivingPolicy) error {
+	purchase, err := retreiveSinglePurchase(db, r.PurchaseId)
+	if err != nil {
+		return err
+	}
+
+	if purchase.ConfirmedAt == 0 || purchase.ConcludedAt != 0 {
+		return errors.New("[ERROR] Goods can only be received for confirmed purchases")
+	}
+
+	if len(r.Lines) == 0 {
+		return errors.New("[ERROR] Receipt must have at least one line")
+	}
+
+	pproducts := purchase.PurschaseOrder.Pproducts
+	now := int(time.Now().Unix())
+	serialized := map[int]bool{}
+	incoming := map[int]int{}
+	tx := db.Begin()
+	for i, line := range r.Lines {
+		if line.Quantity <= 0 {
+			tx.Rollback()
+			return errors.New("[ERROR] Received quantity must be greater than 0")
+		}
+
+		pp := findPurchaseProduct(pproducts, line.PurchaseProductId)
+		if pp == nil {
+			tx.Rollback()
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+
+		product := Product{}
+		if err := tx.Where(Product{ID: pp.ProductId}).First(&product).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if product.TemperatureSensitive && (line.LotNumber == "" || line.Location == "") {
+			tx.Rollback()
+			return errors.New("[ERROR] Temperature sensitive products must be received with a lot number and a storage location")
+		}
+
+		r.Lines[i].ProductId = pp.ProductId
+		serialized[pp.ProductId] = product.Serialized
+		r.Lines[i].ShortShelfLife = product.shortShelfLife(line.ExpiresAt, now)
+		r.Lines[i].Rejected = r.Lines[i].ShortShelfLife && receiving.ShortShelfLife == ShortShelfLifeReject
+		if r.Lines[i].Rejected {
+			continue
+		}
+
+		if _, ok := incoming[pp.ID]; !ok {
+			held, err := heldForInspection(tx, pp.ID)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			incoming[pp.ID] = pp.ReceivedQuantity + held
+		}
+
+		incoming[pp.ID] += line.Quantity
+		if exceeds(float64(incoming[pp.ID]), float64(pp.Quantity), receiving.QuantityTolerance) {
+			tx.Rollback()
+			return errors.New("[ERROR] Received quantity exceeds the quantity ordered of purchase product " + strconv.Itoa(pp.ID))
+		}
+
+		factor, err := unitFactor(tx, pp.ProductId, pp.Unit)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		r.Lines[i].BaseQuantity = line.Quantity * factor
+
+		if r.Quarantine || r.Lines[i].ShortShelfLife {
+			continue
+		}
+
+		pp.ReceivedQuantity += line.Quantity
+		if err := tx.Model(pp).UpdateColumn(PurchaseProduct{ReceivedQuantity: pp.ReceivedQuantity}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := addStock(tx, pp.ProductId, line.Quantity*factor); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if line.LotNumber != "" {
+			lot, err := addLotStock(tx, pp.ProductId, line.LotNumber, line.ExpiresAt, line.Quantity*factor)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			r.Lines[i].LotId = lot.ID
+
+			if err := moveLot(tx, lot, line.Location); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	r.ID = 0
+	r.ReceivedAt = now
+	if err := tx.Create(r).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	r.Inspections = []Inspection{}
+	productIds := []int{}
+	for _, line := range r.Lines {
+		if line.Rejected {
+			continue
+		}
+
+		if serialized[line.ProductId] {
+			status := SerialInStock
+			if r.Quarantine || line.ShortShelfLife {
+				status = SerialQuarantine
+			}
+
+			if err := registerSerials(tx, line, status); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if !r.Quarantine && !line.ShortShelfLife {
+			productIds = append(productIds, line.ProductId)
+			continue
+		}
+
+		reason := "received in quarantine"
+		if line.ShortShelfLife {
+			reason = "short shelf life"
+		}
+
+		in, err := holdForInspection(tx, line, reason)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		r.Inspections = append(r.Inspections, *in)
+	}
+
+	served, err := fulfillBackordersOfStock(tx, productIds, policy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	r.Backorders = served
+
+	return tx.Commit().Error
+}
+
+// Retreive receipts from database
+func (r *Receipt) Retreive(db *gorm.DB) ([]Receipt, error) {
+	var receipts []Receipt
+	if err := db.Where(*r).Find(&receipts).Error; err != nil {
+		return nil, err
+	}
+
+	for i, receipt := range receipts {
+		lines := []ReceiptLine{}
+		if err := db.Model(receipt).Related(&lines, "Lines").Error; err != nil {
+			return nil, err
+		}
+		receipts[i].Lines = lines
+	}
+
+	return receipts, nil
+}
+
+// addStock increases the current quantity of the product, or the assembled quantity of a kit
+func addStock(db *gorm.DB, productId int, quantity int) error {
+	p := Product{}
+	if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+		return err
+	}
+
+	if p.IsKit {
+		return db.Model(&p).UpdateColumn("assembled_quantity", p.AssembledQuantity+quantity).Error
+	}
+
+	p.CurrQuantity += quantity
+	if err := p.Update(db); err != nil {
+		return err
+	}
+
+	if p.Controlled {
+		return registerMovement(db, &p, MovementStockIn, quantity, "", Signoff{})
+	}
+	return nil
+}
+
+// shortShelfLife tells if goods expiring at expiresAt have less than the minimum remaining shelf
+// life of the product. Goods without an expiry date can't be checked and are considered short
+func (p *Product) shortShelfLife(expiresAt int, now int) bool {
+	if p.MinShelfLifeDays <= 0 {
+		return false
+	}
+	return expiresAt == 0 || expiresAt-now < p.MinShelfLifeDays*secondsPerDay
+}
+
+func findPurchaseProduct(pproducts []PurchaseProduct, id int) *PurchaseProduct {
+	for i := range pproducts {
+		if pproducts[i].ID == id {
+			return &pproducts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+const secondsPerDay = 24 * 60 * 60
+
+// SupplierScorecard holds the delivery, price and shelf life KPIs of a supplier over a period
+type SupplierScorecard struct {
+	SupplierId           int     `json:"supplier_id"`
+	SupplierName         string  `json:"supplier_name"`
+	Purchases            int     `json:"purchases"`
+	AverageLeadTimeDays  float64 `json:"average_lead_time_days"`
+	LeadTimeVarianceDays float64 `json:"lead_time_variance_days"`
+	OnTimeRate           float64 `json:"on_time_rate"`
+	FillRate             float64 `json:"fill_rate"`
+	PriceVariance        float64 `json:"price_variance"`
+	ReceivedLines        int     `json:"received_lines"`
+	ShortShelfLifeLines  int     `json:"short_shelf_life_lines"`
+	ShelfLifeCompliance  float64 `json:"shelf_life_compliance"`
+}
+
+// SupplierScorecards computes the scorecard of every supplier using the purchases concluded between from and to.
+// Lead time goes from confirmation to conclusion, fill rate compares received and ordered quantities
+// and price variance compares the purchased unit value with the product's current value. Shelf life
+// compliance is the fraction of receipt lines delivered with the minimum remaining shelf life
+func SupplierScorecards(db *gorm.DB, from int, to int) ([]SupplierScorecard, error) {
+	suppliers := []Supplier{}
+	if err := db.Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+
+	scorecards := []SupplierScorecard{}
+	for _, supplier := range suppliers {
+		purchs := []Purchase{}
+		err := db.Where("supplier_id = ? and confirmed_at != 0 and concluded_at != 0 and concluded_at >= ? and concluded_at <= ?", supplier.ID, from, to).Find(&purchs).Error
+		if err != nil {
+			return nil, err
+		}
+
+		scorecard := SupplierScorecard{SupplierId: supplier.ID, SupplierName: supplier.Name, Purchases: len(purchs)}
+		if len(purchs) == 0 {
+			scorecards = append(scorecards, scorecard)
+			continue
+		}
+
+		scorecard.computeDelivery(purchs)
+
+		if err := scorecard.computeLines(db, purchs); err != nil {
+			return nil, err
+		}
+
+		if err := scorecard.computeShelfLife(db, purchs); err != nil {
+			return nil, err
+		}
+
+		scorecards = append(scorecards, scorecard)
+	}
+
+	return scorecards, nil
+}
+
+// computeDelivery sets the lead time and on time KPIs
+func (sc *SupplierScorecard) computeDelivery(purchs []Purchase) {
+	leadTimes := []float64{}
+	promised, onTime := 0, 0
+	for _, p := range purchs {
+		leadTimes = append(leadTimes, float64(p.ConcludedAt-p.ConfirmedAt)/secondsPerDay)
+
+		if p.ExpectedAt != 0 {
+			promised++
+			if p.ConcludedAt <= p.ExpectedAt {
+				onTime++
+			}
+		}
+	}
+
+	sc.AverageLeadTimeDays, sc.LeadTimeVarianceDays = meanAndVariance(leadTimes)
+	if promised > 0 {
+		sc.OnTimeRate = float64(onTime) / float64(promised)
+	}
+}
+
+// computeLines sets the fill rate and price variance KPIs
+func (sc *SupplierScorecard) computeLines(db *gorm.DB, purchs []Purchase) error {
+	ordered, received := 0, 0
+	variances := []float64{}
+	for _, p := range purchs {
+		pproducts := []PurchaseProduct{}
+		if err := db.Where(PurchaseProduct{OrderId: p.OrderId}).Find(&pproducts).Error; err != nil {
+			return err
+		}
+
+		for _, pp := range pproducts {
+			ordered += pp.Quantity
+			received += pp.ReceivedQuantity
+
+			product := Product{}
+			if err := db.Where(Product{ID: pp.ProductId}).First(&product).Error; err != nil {
+				// products deleted since the purchase have no value to compare with
+				if err.Error() == "record not found" {
+					continue
+				}
+				return err
+			}
+
+			if product.CurrentValue > 0 && pp.Quantity > 0 {
+				factor, err := unitFactor(db, pp.ProductId, pp.Unit)
+				if err != nil {
+					return err
+				}
+
+				// the line is priced by purchasing unit, the product's value is by base unit
+				baseValue := pp.UnitValue() / float64(factor)
+				variances = append(variances, (baseValue-product.CurrentValue)/product.CurrentValue)
+			}
+		}
+	}
+
+	if ordered > 0 {
+		sc.FillRate = float64(received) / float64(ordered)
+	}
+	sc.PriceVariance, _ = meanAndVariance(variances)
+	return nil
+}
+
+// computeShelfLife sets the shelf life KPIs from the receipts of the purchases
+func (sc *SupplierScorecard) computeShelfLife(db *gorm.DB, purchs []Purchase) error {
+	for _, p := range purchs {
+		receipts, err := (&Receipt{PurchaseId: p.ID}).Retreive(db)
+		if err != nil {
+			return err
+		}
+
+		for _, receipt := range receipts {
+			for _, line := range receipt.Lines {
+				sc.ReceivedLines++
+				if line.ShortShelfLife {
+					sc.ShortShelfLifeLines++
+				}
+			}
+		}
+	}
+
+	if sc.ReceivedLines > 0 {
+		sc.ShelfLifeCompliance = float64(sc.ReceivedLines-sc.ShortShelfLifeLines) / float64(sc.ReceivedLines)
+	}
+	return nil
+}
+
+func meanAndVariance(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+
+	return mean, variance
+}
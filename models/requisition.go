@@ -0,0 +1,324 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	RequisitionSubmitted   = "submitted"
+	RequisitionApproved    = "approved"
+	RequisitionRejected    = "rejected"
+	RequisitionBackordered = "backordered"
+	RequisitionFulfilled   = "fulfilled"
+)
+
+// Requisition is the internal request of a clinical department for products of the pharmacy.
+// Once approved it is picked, producing the withdrawals. If there isn't enough stock the
+// requisition stays open as a backorder until it is picked again. Priority is the criticality
+// of the patients it serves.
+// Open requisitions are the reservations of the pharmacy: when stock is short, the share the
+// allocation policy gives to each of them is kept for it, so a requisition picked alone doesn't
+// take the stock reserved to the others
+type Requisition struct {
+	ID          int               `json:"id"`
+	Department  string            `json:"department" sql:"size:255"`
+	Requester   string            `json:"requester" sql:"size:255"`
+	Priority    int               `json:"priority"`
+	Approver    string            `json:"approver" sql:"size:255"`
+	Comment     string            `json:"comment" sql:"size:255"`
+	Status      string            `json:"status" sql:"size:255"`
+	CreatedAt   int               `json:"created_at"`
+	ApprovedAt  int               `json:"approved_at"`
+	FulfilledAt int               `json:"fulfilled_at"`
+	Lines       []RequisitionLine `json:"lines"`
+}
+
+// RequisitionLine is the quantity of a product requested, the quantity approved by the pharmacy
+// and the quantity picked so far
+type RequisitionLine struct {
+	ID                int `json:"id"`
+	RequisitionId     int `json:"requisition_id"`
+	ProductId         int `json:"product_id"`
+	RequestedQuantity int `json:"requested_quantity"`
+	ApprovedQuantity  int `json:"approved_quantity"`
+	PickedQuantity    int `json:"picked_quantity"`
+}
+
+// Outstanding returns the approved quantity that wasn't picked yet
+func (rl *RequisitionLine) Outstanding() int {
+	return rl.ApprovedQuantity - rl.PickedQuantity
+}
+
+// Save new requisition on database. Serialized products can't be requisitioned, as picking doesn't
+// name the units issued
+func (req *Requisition) Save(db *gorm.DB) error {
+	if req.Department == "" || req.Requester == "" {
+		return errors.New("[ERROR] Department and requester must be informed")
+	}
+
+	if len(req.Lines) == 0 {
+		return errors.New("[ERROR] Requisition must have at least one line")
+	}
+
+	for i, line := range req.Lines {
+		if line.RequestedQuantity <= 0 {
+			return errors.New("[ERROR] Requested quantity must be greater than 0")
+		}
+
+		p := Product{}
+		if err := db.Where(Product{ID: line.ProductId}).First(&p).Error; err != nil {
+			return err
+		}
+
+		if p.Serialized {
+			return errors.New("[ERROR] Serialized products can't be requisitioned, they are consumed with the serial numbers of the units issued")
+		}
+
+		req.Lines[i].ID = 0
+		req.Lines[i].ApprovedQuantity = 0
+		req.Lines[i].PickedQuantity = 0
+	}
+
+	req.ID = 0
+	req.Status = RequisitionSubmitted
+	req.Approver = ""
+	req.CreatedAt = int(time.Now().Unix())
+	req.ApprovedAt = 0
+	req.FulfilledAt = 0
+
+	return db.Create(req).Error
+}
+
+// Retreive requisitions from database
+func (req *Requisition) Retreive(db *gorm.DB) ([]Requisition, error) {
+	var reqs []Requisition
+	if err := db.Where(*req).Find(&reqs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, r := range reqs {
+		lines := []RequisitionLine{}
+		if err := db.Model(r).Related(&lines, "Lines").Error; err != nil {
+			return nil, err
+		}
+		reqs[i].Lines = lines
+	}
+
+	return reqs, nil
+}
+
+// Approve the requisition. The pharmacy may adjust the quantity of the lines informed in
+// adjustments, the other lines are approved with the requested quantity
+func (req *Requisition) Approve(db *gorm.DB, approver string, adjustments []RequisitionLine) error {
+	if approver == "" {
+		return errors.New("[ERROR] Approver must be informed")
+	}
+
+	if err := req.retreiveWithStatus(db, RequisitionSubmitted); err != nil {
+		return err
+	}
+
+	for _, adj := range adjustments {
+		if req.line(adj.ID) == nil {
+			return errors.New("[ERROR] Line is not part of the requisition")
+		}
+
+		if adj.ApprovedQuantity < 0 {
+			return errors.New("[ERROR] Approved quantity can't be negative")
+		}
+	}
+
+	tx := db.Begin()
+	for i := range req.Lines {
+		line := &req.Lines[i]
+		line.ApprovedQuantity = line.RequestedQuantity
+		for _, adj := range adjustments {
+			if adj.ID == line.ID {
+				line.ApprovedQuantity = adj.ApprovedQuantity
+			}
+		}
+
+		if err := tx.Model(line).UpdateColumn("approved_quantity", line.ApprovedQuantity).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	req.Status = RequisitionApproved
+	req.Approver = approver
+	req.ApprovedAt = int(time.Now().Unix())
+	if err := tx.Model(req).UpdateColumns(Requisition{Status: req.Status, Approver: req.Approver, ApprovedAt: req.ApprovedAt}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Reject the requisition
+func (req *Requisition) Reject(db *gorm.DB, approver string, comment string) error {
+	if approver == "" || comment == "" {
+		return errors.New("[ERROR] Approver and comment must be informed when rejecting a requisition")
+	}
+
+	if err := req.retreiveWithStatus(db, RequisitionSubmitted); err != nil {
+		return err
+	}
+
+	req.Status = RequisitionRejected
+	req.Approver = approver
+	req.Comment = comment
+	return db.Model(req).UpdateColumns(Requisition{Status: req.Status, Approver: req.Approver, Comment: req.Comment}).Error
+}
+
+// Pick the outstanding quantities of an approved or backordered requisition from stock, registering
+// a withdrawal for each product picked. When the stock of a product doesn't cover every open requisition
+// it is shared by the allocation policy, and the requisition only picks its share. Products picked
+// partially keep the requisition open as a backorder. Controlled products are picked with the signoff.
+// The withdrawals created are returned
+func (req *Requisition) Pick(db *gorm.DB, policy AllocationPolicy, signoff Signoff) ([]Withdrawal, error) {
+	if err := req.retreiveWithStatus(db, RequisitionApproved, RequisitionBackordered); err != nil {
+		return nil, err
+	}
+
+	withdrawals := []Withdrawal{}
+	backordered := false
+	tx := db.Begin()
+	for i := range req.Lines {
+		line := &req.Lines[i]
+		if line.Outstanding() <= 0 {
+			continue
+		}
+
+		p := Product{}
+		if err := tx.Where(Product{ID: line.ProductId}).First(&p).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if err := p.loadAvailability(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		quantity, err := req.allocate(tx, policy, p, line)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if quantity < line.Outstanding() {
+			backordered = true
+		}
+
+		if quantity == 0 {
+			continue
+		}
+
+		w := NewWithdrawl(p, quantity)
+		w.RequisitionId = req.ID
+		w.Department = req.Department
+		w.Requester = req.Requester
+		if err := withdrawStock(tx, w, signoff); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		withdrawals = append(withdrawals, *w)
+
+		line.PickedQuantity += quantity
+		if err := tx.Model(line).UpdateColumn("picked_quantity", line.PickedQuantity).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	req.Status = RequisitionFulfilled
+	req.FulfilledAt = int(time.Now().Unix())
+	if backordered {
+		req.Status = RequisitionBackordered
+		req.FulfilledAt = 0
+	}
+
+	if err := tx.Model(req).UpdateColumns(map[string]interface{}{"status": req.Status, "fulfilled_at": req.FulfilledAt}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return withdrawals, tx.Commit().Error
+}
+
+// allocate returns the quantity of the product the line may pick, sharing the stock with the lines of
+// the other open requisitions. Only the allocation of this line is applied, and logged: the shares of
+// the other requisitions stay reserved for them until they are picked
+func (req *Requisition) allocate(db *gorm.DB, policy AllocationPolicy, p Product, line *RequisitionLine) (int, error) {
+	open := []Requisition{}
+	if err := db.Where("status in (?) and id <> ?", []string{RequisitionApproved, RequisitionBackordered}, req.ID).Find(&open).Error; err != nil {
+		return 0, err
+	}
+
+	requests := []AllocationRequest{req.allocationRequest(line)}
+	for _, other := range open {
+		lines := []RequisitionLine{}
+		if err := db.Where(RequisitionLine{RequisitionId: other.ID, ProductId: p.ID}).Find(&lines).Error; err != nil {
+			return 0, err
+		}
+
+		for i := range lines {
+			if lines[i].Outstanding() > 0 {
+				requests = append(requests, other.allocationRequest(&lines[i]))
+			}
+		}
+	}
+
+	allocations := policy.Allocate(p.CurrQuantity, requests, int(time.Now().Unix()))
+	if err := policy.log(db, p.ID, p.CurrQuantity, requests, allocations[:1]); err != nil {
+		return 0, err
+	}
+	return allocations[0].Allocated, nil
+}
+
+// allocationRequest returns the outstanding quantity of the line as a request for stock
+func (req *Requisition) allocationRequest(line *RequisitionLine) AllocationRequest {
+	return AllocationRequest{
+		Source:      AllocationRequisition,
+		SourceId:    req.ID,
+		Criticality: req.Priority,
+		Department:  req.Department,
+		Requester:   req.Requester,
+		CreatedAt:   req.CreatedAt,
+		Quantity:    line.Outstanding(),
+	}
+}
+
+// retreiveWithStatus loads the requisition and checks it is in one of the given status
+func (req *Requisition) retreiveWithStatus(db *gorm.DB, status ...string) error {
+	reqs, err := (&Requisition{ID: req.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(reqs) != 1 {
+		return errors.New("record not found")
+	}
+
+	for _, s := range status {
+		if reqs[0].Status == s {
+			*req = reqs[0]
+			return nil
+		}
+	}
+
+	return errors.New("[ERROR] Requisition can't be changed when its status is " + reqs[0].Status)
+}
+
+func (req *Requisition) line(id int) *RequisitionLine {
+	for i := range req.Lines {
+		if req.Lines[i].ID == id {
+			return &req.Lines[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Rfq is the request for quotation sent to a supplier for the lines of an order
+type Rfq struct {
+	ID         int               `json:"id"`
+	OrderId    int               `json:"order_id"`
+	SupplierId int               `json:"supplier_id"`
+	CreatedAt  int               `json:"created_at"`
+	ClosedAt   int               `json:"closed_at"`
+	Supplier   Supplier          `json:"supplier" sql:"-"`
+	Lines      []PurchaseProduct `json:"lines" sql:"-"`
+	Quotes     []Quote           `json:"quotes"`
+}
+
+// Quote is the unit price and lead time a supplier offers for one line of an order
+type Quote struct {
+	ID                int     `json:"id"`
+	RfqId             int     `json:"rfq_id"`
+	SupplierId        int     `json:"supplier_id"`
+	PurchaseProductId int     `json:"purchase_product_id"`
+	Price             float64 `json:"price"`
+	LeadTimeDays      int     `json:"lead_time_days"`
+	ValidUntil        int     `json:"valid_until"`
+	SubmittedAt       int     `json:"submitted_at"`
+	Awarded           bool    `json:"awarded"`
+}
+
+// LineQuotes groups every quote received for a line, cheapest first
+type LineQuotes struct {
+	Line   PurchaseProduct `json:"line"`
+	Quotes []Quote         `json:"quotes"`
+}
+
+// Award chooses the quote that will supply a line
+type Award struct {
+	PurchaseProductId int `json:"purchase_product_id"`
+	QuoteId           int `json:"quote_id"`
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////// RFQ METHODS ///////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+
+// Retreive rfq from database along with its supplier, lines and quotes
+func (rfq *Rfq) Retreive(db *gorm.DB) ([]Rfq, error) {
+	var rfqs []Rfq
+	if err := db.Where(*rfq).Find(&rfqs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, r := range rfqs {
+		if err := db.Where(Supplier{ID: r.SupplierId}).First(&r.Supplier).Error; err != nil {
+			return nil, err
+		}
+
+		if err := db.Where("order_id = ? and quantity > ?", r.OrderId, 0).Find(&r.Lines).Error; err != nil {
+			return nil, err
+		}
+
+		quotes := []Quote{}
+		if err := db.Model(r).Related(&quotes, "Quotes").Error; err != nil {
+			return nil, err
+		}
+		r.Quotes = quotes
+		rfqs[i] = r
+	}
+
+	return rfqs, nil
+}
+
+// SubmitQuotes saves the supplier's quotes. A quote for a line that was already
+// quoted on this rfq replaces the previous one
+func (rfq *Rfq) SubmitQuotes(db *gorm.DB, quotes []Quote) error {
+	rfqs, err := (&Rfq{ID: rfq.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(rfqs) != 1 {
+		return errors.New("record not found")
+	}
+	*rfq = rfqs[0]
+
+	if rfq.ClosedAt != 0 {
+		return errors.New("[ERROR] Rfq is already closed")
+	}
+
+	now := int(time.Now().Unix())
+	for _, q := range quotes {
+		if !rfq.hasLine(q.PurchaseProductId) {
+			return errors.New("[ERROR] Purchase product is not part of the rfq")
+		}
+
+		if q.Price <= 0 {
+			return errors.New("[ERROR] Price must be greater than 0")
+		}
+
+		if q.ValidUntil <= now {
+			return errors.New("[ERROR] Quote validity must be in the future")
+		}
+	}
+
+	for _, q := range quotes {
+		q.ID = 0
+		q.RfqId = rfq.ID
+		q.SupplierId = rfq.SupplierId
+		q.SubmittedAt = now
+		q.Awarded = false
+
+		for _, previous := range rfq.Quotes {
+			if previous.PurchaseProductId == q.PurchaseProductId {
+				q.ID = previous.ID
+			}
+		}
+
+		if err := db.Save(&q).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rfq *Rfq) hasLine(purchaseProductId int) bool {
+	for _, line := range rfq.Lines {
+		if line.ID == purchaseProductId {
+			return true
+		}
+	}
+	return false
+}
+
+//////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+///////////////////////////////// RFQ FUNCTIONS //////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+//////////////////////////////////////////////////////////////////////////////////
+
+// NewRfqsFromOrder sends the lines of an open order to each of the given suppliers
+func NewRfqsFromOrder(db *gorm.DB, order *Order, supplierIds []int) ([]Rfq, error) {
+	if err := order.retreiveOpen(db); err != nil {
+		return nil, err
+	}
+
+	if len(supplierIds) == 0 {
+		return nil, errors.New("[ERROR] At least one supplier must be informed")
+	}
+
+	rfqs := []Rfq{}
+	for _, supplierId := range supplierIds {
+		supplier := Supplier{ID: supplierId}
+		suppliers, err := supplier.Retreive(db)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(suppliers) != 1 {
+			return nil, errors.New("[ERROR] Supplier not found")
+		}
+
+		rfq := Rfq{OrderId: order.ID, SupplierId: supplierId, CreatedAt: int(time.Now().Unix())}
+		if err := db.Create(&rfq).Error; err != nil {
+			return nil, err
+		}
+
+		rfq.Supplier = suppliers[0]
+		for _, line := range order.Pproducts {
+			if line.Quantity > 0 {
+				rfq.Lines = append(rfq.Lines, line)
+			}
+		}
+		rfqs = append(rfqs, rfq)
+	}
+
+	return rfqs, nil
+}
+
+// CompareQuotes returns the order's lines side by side with the quotes received for them.
+// Quotes that are no longer valid are left out
+func CompareQuotes(db *gorm.DB, order *Order) ([]LineQuotes, error) {
+	orders, err := (&Order{ID: order.ID}).Retreive(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orders) != 1 {
+		return nil, errors.New("record not found")
+	}
+	*order = orders[0]
+
+	comparison := []LineQuotes{}
+	now := int(time.Now().Unix())
+	for _, line := range order.Pproducts {
+		quotes := []Quote{}
+		if err := db.Where("purchase_product_id = ? and valid_until >= ?", line.ID, now).Find(&quotes).Error; err != nil {
+			return nil, err
+		}
+
+		sort.Sort(byPrice(quotes))
+		comparison = append(comparison, LineQuotes{Line: line, Quotes: quotes})
+	}
+
+	return comparison, nil
+}
+
+type byPrice []Quote
+
+func (q byPrice) Len() int           { return len(q) }
+func (q byPrice) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q byPrice) Less(i, j int) bool { return q[i].Price < q[j].Price }
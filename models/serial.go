@@ -0,0 +1,258 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	SerialQuarantine = "quarantine"
+	SerialInStock    = "in_stock"
+	SerialRejected   = "rejected"
+	SerialWithdrawn  = "withdrawn"
+
+	SerialEventReceived  = "received"
+	SerialEventReleased  = "released"
+	SerialEventRejected  = "rejected"
+	SerialEventWithdrawn = "withdrawn"
+	SerialEventReturned  = "returned"
+)
+
+var errSerialsRequired = errors.New("[ERROR] Serial numbers must be informed to withdraw serialized products")
+
+// SerialUnit is one unit of a serialized product, like a glucometer. Units are registered with
+// their serial numbers when received and must be named when withdrawn. Units received in quarantine
+// can't be withdrawn until released by their inspection. Events holds the history of the unit
+type SerialUnit struct {
+	ID           int           `json:"id"`
+	ProductId    int           `json:"product_id"`
+	SerialNumber string        `json:"serial_number" sql:"size:255"`
+	Status       string        `json:"status" sql:"size:255"`
+	CreatedAt    int           `json:"created_at"`
+	Events       []SerialEvent `json:"events"`
+}
+
+// SerialEvent is something that happened to a unit: received, withdrawn to someone or returned
+type SerialEvent struct {
+	ID            int    `json:"id"`
+	SerialUnitId  int    `json:"serial_unit_id"`
+	Event         string `json:"event" sql:"size:255"`
+	ReceiptLineId int    `json:"receipt_line_id"`
+	WithdrawalId  int    `json:"withdrawal_id"`
+	Requester     string `json:"requester" sql:"size:255"`
+	Department    string `json:"department" sql:"size:255"`
+	PatientRef    string `json:"patient_ref" sql:"size:255"`
+	Reason        string `json:"reason" sql:"size:255"`
+	RecordedAt    int    `json:"recorded_at"`
+}
+
+// Retreive serial units from database, with their history
+func (su *SerialUnit) Retreive(db *gorm.DB) ([]SerialUnit, error) {
+	var sus []SerialUnit
+	if err := db.Where(*su).Find(&sus).Error; err != nil {
+		return nil, err
+	}
+
+	for i, u := range sus {
+		events := []SerialEvent{}
+		if err := db.Where(SerialEvent{SerialUnitId: u.ID}).Order("recorded_at, id").Find(&events).Error; err != nil {
+			return nil, err
+		}
+		sus[i].Events = events
+	}
+
+	return sus, nil
+}
+
+// Return puts a withdrawn unit back in stock. returned holds who returned it and why
+func (su *SerialUnit) Return(db *gorm.DB, returned SerialEvent) error {
+	sus, err := su.Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(sus) == 0 {
+		return errors.New("record not found")
+	}
+
+	if len(sus) > 1 {
+		return errors.New("[ERROR] Serial number " + su.SerialNumber + " belongs to more than one product, inform the product")
+	}
+	*su = sus[0]
+
+	if su.Status != SerialWithdrawn {
+		return errors.New("[ERROR] Only withdrawn units can be returned")
+	}
+
+	// returning the unit first locks it, concurrent returns find it no longer withdrawn
+	tx := db.Begin()
+	update := tx.Model(su).Where("status = ?", SerialWithdrawn).UpdateColumn("status", SerialInStock)
+	if update.Error != nil {
+		tx.Rollback()
+		return update.Error
+	}
+
+	if update.RowsAffected != 1 {
+		tx.Rollback()
+		return errors.New("[ERROR] Only withdrawn units can be returned")
+	}
+	su.Status = SerialInStock
+
+	if err := addStock(tx, su.ProductId, 1); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	returned.ID = 0
+	returned.SerialUnitId = su.ID
+	returned.Event = SerialEventReturned
+	returned.ReceiptLineId = 0
+	returned.WithdrawalId = 0
+	returned.RecordedAt = int(time.Now().Unix())
+	if err := tx.Create(&returned).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	su.Events = append(su.Events, returned)
+
+	return tx.Commit().Error
+}
+
+// registerSerials registers the units received by a receipt line with the status, in stock or in
+// quarantine. One serial number must be informed for each unit received
+func registerSerials(db *gorm.DB, line ReceiptLine, status string) error {
+	if len(line.Serials) != line.BaseQuantity {
+		return errors.New("[ERROR] " + strconv.Itoa(line.BaseQuantity) + " serial numbers expected, " + strconv.Itoa(len(line.Serials)) + " informed")
+	}
+
+	seen := map[string]bool{}
+	for _, serial := range line.Serials {
+		if serial == "" || seen[serial] {
+			return errors.New("[ERROR] Serial numbers must be distinct")
+		}
+		seen[serial] = true
+
+		units := []SerialUnit{}
+		if err := db.Where(SerialUnit{ProductId: line.ProductId, SerialNumber: serial}).Find(&units).Error; err != nil {
+			return err
+		}
+
+		if len(units) != 0 {
+			return errors.New("[ERROR] Serial number " + serial + " is already registered")
+		}
+
+		unit := SerialUnit{ProductId: line.ProductId, SerialNumber: serial, Status: status, CreatedAt: int(time.Now().Unix())}
+		if err := db.Create(&unit).Error; err != nil {
+			return err
+		}
+
+		event := SerialEvent{SerialUnitId: unit.ID, Event: SerialEventReceived, ReceiptLineId: line.ID, RecordedAt: unit.CreatedAt}
+		if err := db.Create(&event).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inspectSerials records the result of the inspection of serialized goods received in quarantine:
+// the units released go in stock and the others are rejected. released names the units released,
+// it may be left empty when all or none of them are
+func inspectSerials(db *gorm.DB, in *Inspection, released []string) error {
+	received := []SerialEvent{}
+	if err := db.Where(SerialEvent{ReceiptLineId: in.ReceiptLineId, Event: SerialEventReceived}).Find(&received).Error; err != nil {
+		return err
+	}
+
+	all := in.ReleasedQuantity == len(received)
+	if !all && in.ReleasedQuantity != 0 && len(released) != in.ReleasedQuantity {
+		return errors.New("[ERROR] " + strconv.Itoa(in.ReleasedQuantity) + " serial numbers of released units expected, " + strconv.Itoa(len(released)) + " informed")
+	}
+
+	named := map[string]bool{}
+	for _, serial := range released {
+		named[serial] = true
+	}
+
+	now := int(time.Now().Unix())
+	found := 0
+	for _, r := range received {
+		unit := SerialUnit{}
+		if err := db.Where(SerialUnit{ID: r.SerialUnitId}).First(&unit).Error; err != nil {
+			return err
+		}
+
+		if unit.Status != SerialQuarantine {
+			continue
+		}
+
+		status, event := SerialRejected, SerialEventRejected
+		if all || named[unit.SerialNumber] {
+			status, event = SerialInStock, SerialEventReleased
+			if named[unit.SerialNumber] {
+				found++
+			}
+		}
+
+		if err := db.Model(&unit).UpdateColumn("status", status).Error; err != nil {
+			return err
+		}
+
+		if err := db.Create(&SerialEvent{SerialUnitId: unit.ID, Event: event, Reason: in.Notes, RecordedAt: now}).Error; err != nil {
+			return err
+		}
+	}
+
+	if found != len(named) {
+		return errors.New("[ERROR] Released serial numbers must be units of the inspection")
+	}
+	return nil
+}
+
+// withdrawSerials records the units issued by a withdrawal of a serialized product. One serial
+// number of a unit in stock must be informed for each unit withdrawn
+func withdrawSerials(db *gorm.DB, w *Withdrawal, serials []string) error {
+	if len(serials) != w.Quantity {
+		return errSerialsRequired
+	}
+
+	seen := map[string]bool{}
+	for _, serial := range serials {
+		if seen[serial] {
+			return errors.New("[ERROR] Serial numbers must be distinct")
+		}
+		seen[serial] = true
+
+		units := []SerialUnit{}
+		if err := db.Where(SerialUnit{ProductId: w.ProductId, SerialNumber: serial}).Find(&units).Error; err != nil {
+			return err
+		}
+
+		if len(units) != 1 || units[0].Status != SerialInStock {
+			return errors.New("[ERROR] Serial number " + serial + " isn't in stock")
+		}
+
+		unit := units[0]
+		if err := db.Model(&unit).UpdateColumn("status", SerialWithdrawn).Error; err != nil {
+			return err
+		}
+
+		event := SerialEvent{
+			SerialUnitId: unit.ID,
+			Event:        SerialEventWithdrawn,
+			WithdrawalId: w.ID,
+			Requester:    w.Requester,
+			Department:   w.Department,
+			PatientRef:   w.PatientRef,
+			RecordedAt:   w.IssuedAt,
+		}
+		if err := db.Create(&event).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
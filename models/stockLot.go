@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	LotAvailable  = "available"
+	LotQuarantine = "quarantine"
+	LotRejected   = "rejected"
+	LotDamaged    = "damaged"
+	LotRecalled   = "recalled"
+)
+
+// StockLot is the stock of a product that belongs to one lot. Lots are registered when goods are
+// received or produced with a lot number, and are withdrawn first expiry first out. Stock received
+// without a lot number isn't tracked by lot. Only available lots can be withdrawn, the stock of the
+// others is kept in quarantine. Location is where the lot is stored
+type StockLot struct {
+	ID        int    `json:"id"`
+	ProductId int    `json:"product_id"`
+	LotNumber string `json:"lot_number" sql:"size:255"`
+	ExpiresAt int    `json:"expires_at"`
+	Quantity  int    `json:"quantity"`
+	Status    string `json:"status" sql:"size:255"`
+	Location  string `json:"location" sql:"size:255"`
+	CreatedAt int    `json:"created_at"`
+}
+
+// Retreive lots from database, the ones expiring first first
+func (sl *StockLot) Retreive(db *gorm.DB) ([]StockLot, error) {
+	var lots []StockLot
+	err := db.Where(*sl).Order("expires_at, id").Find(&lots).Error
+	return lots, err
+}
+
+// addLotStock adds the quantity to the lot of the product, registering the lot when it's new
+func addLotStock(db *gorm.DB, productId int, lotNumber string, expiresAt int, quantity int) (*StockLot, error) {
+	lots := []StockLot{}
+	if err := db.Where(StockLot{ProductId: productId, LotNumber: lotNumber}).Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	if len(lots) == 0 {
+		lot := &StockLot{ProductId: productId, LotNumber: lotNumber, ExpiresAt: expiresAt, Quantity: quantity, Status: LotAvailable, CreatedAt: int(time.Now().Unix())}
+		return lot, db.Create(lot).Error
+	}
+
+	lot := &lots[0]
+	if lot.Status != LotAvailable {
+		return nil, errors.New("[ERROR] Lot " + lotNumber + " is " + lot.Status + " and can't receive stock")
+	}
+
+	lot.Quantity += quantity
+	return lot, db.Model(lot).UpdateColumn("quantity", lot.Quantity).Error
+}
+
+// takeFromLots withdraws the quantity from the given lot or, when lotId is 0, from the lots of the
+// product expiring first. The quantities taken from each lot are returned. Stock that isn't tracked
+// by lot covers whatever the lots don't
+func takeFromLots(db *gorm.DB, productId int, lotId int, quantity int) ([]StockLot, error) {
+	taken := []StockLot{}
+
+	if lotId != 0 {
+		lot := StockLot{}
+		if err := db.Where(StockLot{ID: lotId}).First(&lot).Error; err != nil {
+			return nil, err
+		}
+
+		if lot.ProductId != productId {
+			return nil, errors.New("[ERROR] Lot doesn't belong to the product")
+		}
+
+		if lot.Status != LotAvailable {
+			return nil, errors.New("[ERROR] Lot " + lot.LotNumber + " is " + lot.Status)
+		}
+
+		if lot.Quantity < quantity {
+			return nil, errors.New("[ERROR] Requested quantity exceeds the quantity of the lot")
+		}
+
+		if err := db.Model(&lot).UpdateColumn("quantity", lot.Quantity-quantity).Error; err != nil {
+			return nil, err
+		}
+
+		lot.Quantity = quantity
+		return append(taken, lot), nil
+	}
+
+	lots := []StockLot{}
+	if err := db.Where("product_id = ? and status = ? and quantity > 0", productId, LotAvailable).Order("expires_at, id").Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	for _, lot := range lots {
+		if quantity == 0 {
+			break
+		}
+
+		q := minQuantity(lot.Quantity, quantity)
+		if err := db.Model(&lot).UpdateColumn("quantity", lot.Quantity-q).Error; err != nil {
+			return nil, err
+		}
+
+		quantity -= q
+		lot.Quantity = q
+		taken = append(taken, lot)
+	}
+
+	return taken, nil
+}
+
+// moveLot stores the lot at the location, unless no location is informed
+func moveLot(db *gorm.DB, lot *StockLot, location string) error {
+	if location == "" {
+		return nil
+	}
+
+	lot.Location = location
+	return db.Model(lot).UpdateColumn("location", lot.Location).Error
+}
+
+// Move stores the lot at the location
+func (sl *StockLot) Move(db *gorm.DB, location string) error {
+	if location == "" {
+		return errors.New("[ERROR] Location must be informed")
+	}
+
+	lots, err := (&StockLot{ID: sl.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(lots) != 1 {
+		return errors.New("record not found")
+	}
+
+	*sl = lots[0]
+	return moveLot(db, sl, location)
+}
+
+// quarantineLot sets the status of the lot and moves its stock from the current quantity of the
+// product to quarantine
+func quarantineLot(db *gorm.DB, lot *StockLot, status string) error {
+	p := Product{}
+	if err := db.Where(Product{ID: lot.ProductId}).First(&p).Error; err != nil {
+		return err
+	}
+
+	lot.Status = status
+	if err := db.Model(lot).UpdateColumn("status", lot.Status).Error; err != nil {
+		return err
+	}
+
+	if lot.Quantity == 0 {
+		return nil
+	}
+
+	if p.IsKit {
+		p.AssembledQuantity -= lot.Quantity
+	} else {
+		p.CurrQuantity -= lot.Quantity
+	}
+	p.QuarantinedQuantity += lot.Quantity
+	if err := db.Model(&p).UpdateColumns(map[string]interface{}{
+		"curr_quantity":        p.CurrQuantity,
+		"assembled_quantity":   p.AssembledQuantity,
+		"quarantined_quantity": p.QuarantinedQuantity,
+	}).Error; err != nil {
+		return err
+	}
+
+	if p.Controlled {
+		return registerMovement(db, &p, MovementQuarantine, -lot.Quantity, "lot "+lot.LotNumber+" "+status, Signoff{})
+	}
+	return nil
+}
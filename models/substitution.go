@@ -0,0 +1,234 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SubstitutionGroup is a set of products that can replace each other, like the generic
+// equivalents of a branded drug. Substitutes are exchanged unit for unit in their base unit,
+// which must be the same for every member, and, when several are in stock, the one with the
+// lowest preference is used
+type SubstitutionGroup struct {
+	ID          int                  `json:"id"`
+	Name        string               `json:"name" sql:"size:255"`
+	Description string               `json:"description" sql:"size:255"`
+	Members     []SubstitutionMember `json:"members"`
+}
+
+// SubstitutionMember is a product of a substitution group
+type SubstitutionMember struct {
+	ID                  int `json:"id"`
+	SubstitutionGroupId int `json:"substitution_group_id"`
+	ProductId           int `json:"product_id"`
+	Preference          int `json:"preference"`
+}
+
+// Availability tells if a product has the requested quantity and, when it doesn't, which
+// of its substitutes have
+type Availability struct {
+	ProductId    int       `json:"product_id"`
+	Quantity     int       `json:"quantity"`
+	CurrQuantity int       `json:"curr_quantity"`
+	Available    bool      `json:"available"`
+	Substitutes  []Product `json:"substitutes"`
+}
+
+// Save new substitution group on database
+func (sg *SubstitutionGroup) Save(db *gorm.DB) error {
+	if err := sg.validate(db); err != nil {
+		return err
+	}
+	return db.Create(sg).Error
+}
+
+// Update substitution group on database, replacing its members
+func (sg *SubstitutionGroup) Update(db *gorm.DB) error {
+	if err := sg.validate(db); err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("substitution_group_id = ?", sg.ID).Delete(SubstitutionMember{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Save(sg).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Delete substitution group on database
+func (sg *SubstitutionGroup) Delete(db *gorm.DB) error {
+	if err := db.Where("substitution_group_id = ?", sg.ID).Delete(SubstitutionMember{}).Error; err != nil {
+		return err
+	}
+	return db.Where(sg).Delete(SubstitutionGroup{}).Error
+}
+
+// Retreive substitution groups from database
+func (sg *SubstitutionGroup) Retreive(db *gorm.DB) ([]SubstitutionGroup, error) {
+	var sgs []SubstitutionGroup
+	if err := db.Where(*sg).Find(&sgs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, g := range sgs {
+		members := []SubstitutionMember{}
+		if err := db.Model(g).Related(&members, "Members").Error; err != nil {
+			return nil, err
+		}
+		sgs[i].Members = members
+	}
+
+	return sgs, nil
+}
+
+func (sg *SubstitutionGroup) validate(db *gorm.DB) error {
+	if sg.Name == "" {
+		return errors.New("[ERROR] Substitution group must have a name")
+	}
+
+	if len(sg.Members) < 2 {
+		return errors.New("[ERROR] Substitution group must have at least two products")
+	}
+
+	seen := map[int]bool{}
+	baseUnit := ""
+	for i, m := range sg.Members {
+		if seen[m.ProductId] {
+			return errors.New("[ERROR] A product can only be once in a substitution group")
+		}
+		seen[m.ProductId] = true
+
+		p := Product{}
+		if err := db.Where(Product{ID: m.ProductId}).First(&p).Error; err != nil {
+			return err
+		}
+
+		if i == 0 {
+			baseUnit = p.BaseUnit
+		} else if p.BaseUnit != baseUnit {
+			return errors.New("[ERROR] Products of a substitution group must have the same base unit")
+		}
+
+		sg.Members[i].ID = 0
+		sg.Members[i].SubstitutionGroupId = sg.ID
+	}
+
+	return nil
+}
+
+// Substitutes returns the products that can replace this one and have the quantity in stock,
+// the preferred first. Products whose base unit is no longer the same aren't substitutes
+func (p *Product) Substitutes(db *gorm.DB, quantity int) ([]Product, error) {
+	self := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&self).Error; err != nil {
+		return nil, err
+	}
+
+	groups := []SubstitutionMember{}
+	if err := db.Where(SubstitutionMember{ProductId: p.ID}).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	substitutes := []Product{}
+	seen := map[int]bool{p.ID: true}
+	for _, g := range groups {
+		members := []SubstitutionMember{}
+		if err := db.Where(SubstitutionMember{SubstitutionGroupId: g.SubstitutionGroupId}).Order("preference, id").Find(&members).Error; err != nil {
+			return nil, err
+		}
+
+		for _, m := range members {
+			if seen[m.ProductId] {
+				continue
+			}
+			seen[m.ProductId] = true
+
+			s := Product{}
+			if err := db.Where(Product{ID: m.ProductId}).First(&s).Error; err != nil {
+				return nil, err
+			}
+
+			if err := s.loadAvailability(db); err != nil {
+				return nil, err
+			}
+
+			if s.BaseUnit == self.BaseUnit && s.CurrQuantity >= quantity {
+				substitutes = append(substitutes, s)
+			}
+		}
+	}
+
+	return substitutes, nil
+}
+
+// Availability checks if the product has the quantity in stock, suggesting substitutes when it doesn't
+func (p *Product) Availability(db *gorm.DB, quantity int) (*Availability, error) {
+	pp := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&pp).Error; err != nil {
+		return nil, err
+	}
+
+	if err := pp.loadAvailability(db); err != nil {
+		return nil, err
+	}
+
+	a := &Availability{ProductId: pp.ID, Quantity: quantity, CurrQuantity: pp.CurrQuantity, Available: pp.CurrQuantity >= quantity, Substitutes: []Product{}}
+	if a.Available {
+		return a, nil
+	}
+
+	substitutes, err := pp.Substitutes(db, quantity)
+	if err != nil {
+		return nil, err
+	}
+	a.Substitutes = substitutes
+
+	return a, nil
+}
+
+// ConsumeOrSubstitute consumes the product or, when it doesn't have the quantity in stock, its
+// preferred substitute that has, issuing it to the recipient like ConsumeFor. Controlled and
+// serialized substitutes aren't used, as they require a witness and serial numbers.
+// The withdrawal of a substitute records the product it replaced. The product consumed is returned
+func (p *Product) ConsumeOrSubstitute(db *gorm.DB, quantity int, recipient Withdrawal) (*Product, error) {
+	a, err := p.Availability(db, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Available {
+		return p, p.ConsumeFor(db, quantity, recipient)
+	}
+
+	substitutes := []Product{}
+	for _, s := range a.Substitutes {
+		if !s.Controlled && !s.Serialized {
+			substitutes = append(substitutes, s)
+		}
+	}
+
+	if len(substitutes) == 0 {
+		return nil, errors.New("Requested quantity exceeds the available amount and there's no substitute in stock")
+	}
+
+	s := substitutes[0]
+	tx := db.Begin()
+	w := &Withdrawal{ProductId: s.ID, Quantity: quantity, IssuedAt: int(time.Now().Unix()), SubstitutedProductId: p.ID}
+	w.issuedTo(recipient)
+	w.Serials = nil
+	if err := withdrawStock(tx, w, Signoff{}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &s, tx.Commit().Error
+}
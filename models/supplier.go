@@ -0,0 +1,36 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// Supplier struct that defines who the products are purchased from.
+// LeadTimeDays is used to compute the delivery date of confirmed purchases
+type Supplier struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name" sql:"size:255"`
+	Email        string `json:"email" sql:"size:255"`
+	Phone        string `json:"phone" sql:"size:255"`
+	LeadTimeDays int    `json:"lead_time_days"`
+}
+
+// Save new supplier on database
+func (s *Supplier) Save(db *gorm.DB) error {
+	return db.Create(s).Error
+}
+
+// Update supplier on database
+func (s *Supplier) Update(db *gorm.DB) error {
+	return db.Save(s).Error
+}
+
+// Delete supplier on database
+func (s *Supplier) Delete(db *gorm.DB) error {
+	return db.Where(s).Delete(Supplier{}).Error
+}
+
+// Retreive supplier from database
+func (s *Supplier) Retreive(db *gorm.DB) ([]Supplier, error) {
+	var suppliers []Supplier
+	err := db.Where(*s).Find(&suppliers).Error
+
+	return suppliers, err
+}
@@ -0,0 +1,362 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	ReturnRequested = "requested"
+	ReturnShipped   = "shipped"
+	ReturnCredited  = "credited"
+
+	ReturnFromAvailable = "available"
+	ReturnFromRejected  = "rejected"
+	ReturnFromDamaged   = "damaged"
+)
+
+// SupplierReturn is the RMA document used to send received goods back to the supplier
+type SupplierReturn struct {
+	ID          int                  `json:"id"`
+	PurchaseId  int                  `json:"purchase_id"`
+	SupplierId  int                  `json:"supplier_id"`
+	Reason      string               `json:"reason" sql:"size:255"`
+	Status      string               `json:"status" sql:"size:255"`
+	CreatedAt   int                  `json:"created_at"`
+	ShippedAt   int                  `json:"shipped_at"`
+	CreditedAt  int                  `json:"credited_at"`
+	Lines       []SupplierReturnLine `json:"lines"`
+	CreditNotes []CreditNote         `json:"credit_notes"`
+}
+
+// SupplierReturnLine is the quantity of a purchase product sent back. Stock tells where it's taken
+// from: the available stock, by default, or the stock rejected or damaged on inspection
+type SupplierReturnLine struct {
+	ID                int    `json:"id"`
+	SupplierReturnId  int    `json:"supplier_return_id"`
+	PurchaseProductId int    `json:"purchase_product_id"`
+	ProductId         int    `json:"product_id"`
+	Quantity          int    `json:"quantity"`
+	Stock             string `json:"stock" sql:"size:255"`
+}
+
+// CreditNote is issued by the supplier for a return and reduces the purchase's payable amount
+type CreditNote struct {
+	ID               int     `json:"id"`
+	SupplierReturnId int     `json:"supplier_return_id"`
+	PurchaseId       int     `json:"purchase_id"`
+	Number           string  `json:"number" sql:"size:255"`
+	Amount           float64 `json:"amount"`
+	IssuedAt         int     `json:"issued_at"`
+}
+
+// Save new return on database, deducting the returned quantities from stock. Goods returned from the
+// available stock can't exceed the quantity received and, when controlled, require the signoff of an
+// operator and a witness. Rejected and damaged goods never counted as received and are returned from
+// the quantities kept apart on inspection. Serialized products can't be returned
+func (sr *SupplierReturn) Save(db *gorm.DB, signoff Signoff) error {
+	purchase, err := retreiveSinglePurchase(db, sr.PurchaseId)
+	if err != nil {
+		return err
+	}
+
+	if sr.Reason == "" {
+		return errors.New("[ERROR] A reason must be informed when returning goods")
+	}
+
+	if len(sr.Lines) == 0 {
+		return errors.New("[ERROR] Return must have at least one line")
+	}
+
+	pproducts := purchase.PurschaseOrder.Pproducts
+	tx := db.Begin()
+	for i, line := range sr.Lines {
+		pp := findPurchaseProduct(pproducts, line.PurchaseProductId)
+		if pp == nil {
+			tx.Rollback()
+			return errors.New("[ERROR] Purchase product is not part of the purchase")
+		}
+
+		if line.Quantity <= 0 {
+			tx.Rollback()
+			return errors.New("[ERROR] Returned quantity must be greater than 0")
+		}
+
+		product := Product{}
+		if err := tx.Where(Product{ID: pp.ProductId}).First(&product).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if product.Serialized {
+			tx.Rollback()
+			return errors.New("[ERROR] Serialized products can't be returned to the supplier, their units aren't named by the return")
+		}
+
+		factor, err := unitFactor(tx, pp.ProductId, pp.Unit)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		switch line.Stock {
+		case "", ReturnFromAvailable:
+			if pp.ReturnedQuantity+line.Quantity > pp.ReceivedQuantity {
+				tx.Rollback()
+				return errors.New("[ERROR] Returned quantity can't exceed the received quantity")
+			}
+
+			pp.ReturnedQuantity += line.Quantity
+			if err := tx.Model(pp).UpdateColumn(PurchaseProduct{ReturnedQuantity: pp.ReturnedQuantity}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			if err := removeStock(tx, pp.ProductId, line.Quantity*factor, signoff); err != nil {
+				tx.Rollback()
+				return err
+			}
+			sr.Lines[i].Stock = ReturnFromAvailable
+		case ReturnFromRejected, ReturnFromDamaged:
+			if err := removeInspectedStock(tx, pp.ProductId, line.Stock, line.Quantity*factor); err != nil {
+				tx.Rollback()
+				return err
+			}
+		default:
+			tx.Rollback()
+			return errors.New("[ERROR] Invalid stock to return from: '" + line.Stock + "'")
+		}
+		sr.Lines[i].ProductId = pp.ProductId
+	}
+
+	sr.ID = 0
+	sr.SupplierId = purchase.SupplierId
+	sr.Status = ReturnRequested
+	sr.CreatedAt = int(time.Now().Unix())
+	sr.CreditNotes = nil
+	if err := tx.Create(sr).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Retreive returns from database
+func (sr *SupplierReturn) Retreive(db *gorm.DB) ([]SupplierReturn, error) {
+	var srs []SupplierReturn
+	if err := db.Where(*sr).Find(&srs).Error; err != nil {
+		return nil, err
+	}
+
+	for i, r := range srs {
+		lines := []SupplierReturnLine{}
+		if err := db.Model(r).Related(&lines, "Lines").Error; err != nil {
+			return nil, err
+		}
+		srs[i].Lines = lines
+
+		notes := []CreditNote{}
+		if err := db.Model(r).Related(&notes, "CreditNotes").Error; err != nil {
+			return nil, err
+		}
+		srs[i].CreditNotes = notes
+	}
+
+	return srs, nil
+}
+
+// Ship marks the returned goods as sent to the supplier
+func (sr *SupplierReturn) Ship(db *gorm.DB) error {
+	if err := sr.retreiveWithStatus(db, ReturnRequested); err != nil {
+		return err
+	}
+
+	sr.Status = ReturnShipped
+	sr.ShippedAt = int(time.Now().Unix())
+	return db.Model(sr).UpdateColumns(SupplierReturn{Status: sr.Status, ShippedAt: sr.ShippedAt}).Error
+}
+
+// Credit registers the supplier's credit note, reducing the payable amount of the purchase
+func (sr *SupplierReturn) Credit(db *gorm.DB, note *CreditNote) error {
+	if err := sr.retreiveWithStatus(db, ReturnShipped); err != nil {
+		return err
+	}
+
+	if note.Amount <= 0 {
+		return errors.New("[ERROR] Credit note amount must be greater than 0")
+	}
+
+	purchase, err := retreiveSinglePurchase(db, sr.PurchaseId)
+	if err != nil {
+		return err
+	}
+
+	if purchase.CreditedValue+note.Amount > purchase.TotalValue {
+		return errors.New("[ERROR] Credited amount can't exceed the purchase total value")
+	}
+
+	note.ID = 0
+	note.SupplierReturnId = sr.ID
+	note.PurchaseId = sr.PurchaseId
+	if note.IssuedAt == 0 {
+		note.IssuedAt = int(time.Now().Unix())
+	}
+
+	tx := db.Begin()
+	if err := tx.Create(note).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	purchase.CreditedValue += note.Amount
+	if err := tx.Model(purchase).UpdateColumn(Purchase{CreditedValue: purchase.CreditedValue}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	sr.Status = ReturnCredited
+	sr.CreditedAt = int(time.Now().Unix())
+	if err := tx.Model(sr).UpdateColumns(SupplierReturn{Status: sr.Status, CreditedAt: sr.CreditedAt}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	sr.CreditNotes = append(sr.CreditNotes, *note)
+
+	return tx.Commit().Error
+}
+
+// retreiveWithStatus loads the return and verifies that it's on the expected status
+func (sr *SupplierReturn) retreiveWithStatus(db *gorm.DB, status string) error {
+	srs, err := (&SupplierReturn{ID: sr.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(srs) != 1 {
+		return errors.New("record not found")
+	}
+
+	if srs[0].Status != status {
+		return errors.New("[ERROR] Return must be " + status + ", current status: " + srs[0].Status)
+	}
+
+	*sr = srs[0]
+	return nil
+}
+
+// removeInspectedStock decreases the quantity of the product rejected or damaged on inspection
+func removeInspectedStock(db *gorm.DB, productId int, stock string, quantity int) error {
+	p := Product{}
+	if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+		return err
+	}
+
+	column, kept := "rejected_quantity", p.RejectedQuantity
+	if stock == ReturnFromDamaged {
+		column, kept = "damaged_quantity", p.DamagedQuantity
+	}
+
+	if kept < quantity {
+		return errors.New("Requested quantity exceeds the " + stock + " amount")
+	}
+	return db.Model(&p).UpdateColumn(column, kept-quantity).Error
+}
+
+// removeStock decreases the current quantity of the product, or of the components of a kit
+func removeStock(db *gorm.DB, productId int, quantity int, signoff Signoff) error {
+	_, err := removeStockFromLot(db, productId, 0, quantity, signoff)
+	return err
+}
+
+// removeStockFromLot decreases the current quantity of the product, taking it from the given lot or,
+// when lotId is 0, from the lots expiring first. The quantities taken from each lot are returned.
+// Serialized products can't be removed this way, as their serial numbers are required, and
+// controlled products are only removed with the signoff of an operator and a witness
+func removeStockFromLot(db *gorm.DB, productId int, lotId int, quantity int, signoff Signoff) ([]StockLot, error) {
+	p := Product{}
+	if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+		return nil, err
+	}
+
+	if p.Serialized {
+		return nil, errSerialsRequired
+	}
+	return p.takeStock(db, lotId, quantity, signoff)
+}
+
+// takeStock decreases the current quantity of the product like removeStockFromLot
+func (p *Product) takeStock(db *gorm.DB, lotId int, quantity int, signoff Signoff) ([]StockLot, error) {
+	if p.IsKit {
+		lots, components, err := p.removeComponentsStock(db, quantity, signoff)
+		if err != nil {
+			return nil, err
+		}
+		return lots, saveComponentWithdrawals(db, components, nil)
+	}
+
+	if p.Controlled {
+		if err := signoff.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.CurrQuantity-quantity < 0 {
+		return nil, errors.New("Requested quantity exceeds the available amount")
+	}
+
+	p.CurrQuantity -= quantity
+	if err := p.Update(db); err != nil {
+		return nil, err
+	}
+
+	if p.Controlled {
+		if err := registerMovement(db, p, MovementStockOut, -quantity, "", signoff); err != nil {
+			return nil, err
+		}
+	}
+
+	return takeFromLots(db, p.ID, lotId, quantity)
+}
+
+// issue takes the quantity of the withdrawal from the stock of the product, like takeStock, and
+// saves the withdrawal with the lots it was taken from. The withdrawals of the components of a kit
+// are saved as part of it
+func (p *Product) issue(db *gorm.DB, w *Withdrawal, lotId int, signoff Signoff) error {
+	if !p.IsKit {
+		lots, err := p.takeStock(db, lotId, w.Quantity, signoff)
+		if err != nil {
+			return err
+		}
+
+		w.Lots = withdrawalLots(lots)
+		return w.Save(db)
+	}
+
+	lots, components, err := p.removeComponentsStock(db, w.Quantity, signoff)
+	if err != nil {
+		return err
+	}
+
+	w.Lots = withdrawalLots(lots)
+	if err := w.Save(db); err != nil {
+		return err
+	}
+	return saveComponentWithdrawals(db, components, w)
+}
+
+// withdrawStock issues the withdrawal from the lots of its product expiring first, like issue.
+// Serialized products can't be withdrawn this way, as their serial numbers are required
+func withdrawStock(db *gorm.DB, w *Withdrawal, signoff Signoff) error {
+	p := Product{}
+	if err := db.Where(Product{ID: w.ProductId}).First(&p).Error; err != nil {
+		return err
+	}
+
+	if p.Serialized {
+		return errSerialsRequired
+	}
+	return p.issue(db, w, 0, signoff)
+}
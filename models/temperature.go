@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TemperatureReading is a temperature read at a storage location, like a fridge
+type TemperatureReading struct {
+	ID          int     `json:"id"`
+	Location    string  `json:"location" sql:"size:255"`
+	Temperature float64 `json:"temperature"`
+	ReadAt      int     `json:"read_at"`
+}
+
+// TemperatureExcursion is a lot found at a location whose temperature was out of the storage range
+// of its product. The lot is put on hold, InspectionId being the inspection that will decide if
+// its stock can still be used
+type TemperatureExcursion struct {
+	ID             int     `json:"id"`
+	ReadingId      int     `json:"reading_id"`
+	Location       string  `json:"location" sql:"size:255"`
+	Temperature    float64 `json:"temperature"`
+	ProductId      int     `json:"product_id"`
+	MinTemperature float64 `json:"min_temperature"`
+	MaxTemperature float64 `json:"max_temperature"`
+	LotId          int     `json:"lot_id"`
+	LotNumber      string  `json:"lot_number" sql:"size:255"`
+	Quantity       int     `json:"quantity"`
+	InspectionId   int     `json:"inspection_id"`
+	DetectedAt     int     `json:"detected_at"`
+}
+
+// Save the reading and put on hold the available lots at its location whose products can't be
+// stored at its temperature. The excursions detected are returned. Stock that isn't tracked by
+// lot has no location and can't be put on hold
+func (tr *TemperatureReading) Save(db *gorm.DB) ([]TemperatureExcursion, error) {
+	if tr.Location == "" {
+		return nil, errors.New("[ERROR] Reading must have a location")
+	}
+
+	if tr.ReadAt == 0 {
+		tr.ReadAt = int(time.Now().Unix())
+	}
+
+	tx := db.Begin()
+	tr.ID = 0
+	if err := tx.Create(tr).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	lots := []StockLot{}
+	if err := tx.Where("location = ? and status = ? and quantity > 0", tr.Location, LotAvailable).Find(&lots).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	excursions := []TemperatureExcursion{}
+	for _, lot := range lots {
+		p := Product{}
+		if err := tx.Where(Product{ID: lot.ProductId}).First(&p).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if p.storableAt(tr.Temperature) {
+			continue
+		}
+
+		in := &Inspection{LotId: lot.ID, Reason: "temperature excursion at " + tr.Location + ": " + strconv.FormatFloat(tr.Temperature, 'f', 1, 64)}
+		if err := holdLotForInspection(tx, in); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		excursion := TemperatureExcursion{
+			ReadingId:      tr.ID,
+			Location:       tr.Location,
+			Temperature:    tr.Temperature,
+			ProductId:      p.ID,
+			MinTemperature: p.StorageMinTemperature,
+			MaxTemperature: p.StorageMaxTemperature,
+			LotId:          lot.ID,
+			LotNumber:      lot.LotNumber,
+			Quantity:       in.Quantity,
+			InspectionId:   in.ID,
+			DetectedAt:     tr.ReadAt,
+		}
+		if err := tx.Create(&excursion).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		excursions = append(excursions, excursion)
+	}
+
+	return excursions, tx.Commit().Error
+}
+
+// ExcursionReport lists the excursions detected between from and to, at the location when one is
+// informed, the oldest first
+func ExcursionReport(db *gorm.DB, from int, to int, location string) ([]TemperatureExcursion, error) {
+	excursions := []TemperatureExcursion{}
+	query := db.Where("detected_at >= ? and detected_at <= ?", from, to)
+	if location != "" {
+		query = query.Where("location = ?", location)
+	}
+
+	err := query.Order("detected_at, id").Find(&excursions).Error
+	return excursions, err
+}
+
+// validateStorage checks the storage range of temperature sensitive products
+func (p *Product) validateStorage() error {
+	if p.TemperatureSensitive && p.StorageMinTemperature > p.StorageMaxTemperature {
+		return errors.New("[ERROR] Minimum storage temperature can't be above the maximum")
+	}
+	return nil
+}
+
+// storableAt tells if the product can be stored at the temperature. Products without storage
+// requirements can be stored at any temperature
+func (p *Product) storableAt(temperature float64) bool {
+	if !p.TemperatureSensitive {
+		return true
+	}
+	return temperature >= p.StorageMinTemperature && temperature <= p.StorageMaxTemperature
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+)
+
+// UnitOfMeasure is a unit a product is handled in, Factor being how many base units it holds.
+// For example, with tablets as the base unit, a blister may hold 10 and a box 30
+type UnitOfMeasure struct {
+	ID        int    `json:"id"`
+	ProductId int    `json:"product_id"`
+	Name      string `json:"name" sql:"size:255"`
+	Factor    int    `json:"factor"`
+}
+
+// StockInUnit is the stock of a product expressed in one of its units
+type StockInUnit struct {
+	ProductId    int     `json:"product_id"`
+	Unit         string  `json:"unit"`
+	CurrQuantity float64 `json:"curr_quantity"`
+	MinQuantity  float64 `json:"min_quantity"`
+}
+
+// Factor returns how many base units the unit holds. The base unit, or no unit, holds 1
+func (p *Product) Factor(unit string) (int, error) {
+	if unit == "" || unit == p.BaseUnit {
+		return 1, nil
+	}
+
+	for _, u := range p.Units {
+		if u.Name == unit {
+			return u.Factor, nil
+		}
+	}
+
+	return 0, errors.New("[ERROR] Unknown unit '" + unit + "' for product")
+}
+
+// ToBase converts a quantity in the unit to base units. When no unit is informed the quantity
+// is in the dispensing unit
+func (p *Product) ToBase(db *gorm.DB, quantity int, unit string) (int, error) {
+	pp := Product{}
+	if err := db.Where(Product{ID: p.ID}).First(&pp).Error; err != nil {
+		return 0, err
+	}
+
+	if unit == "" {
+		unit = pp.DispenseUnit
+	}
+
+	factor, err := unitFactor(db, pp.ID, unit)
+	if err != nil {
+		return 0, err
+	}
+	return quantity * factor, nil
+}
+
+// StockIn returns the current and minimum quantities of the product in the unit
+func (p *Product) StockIn(db *gorm.DB, unit string) (*StockInUnit, error) {
+	if err := p.loadUnits(db); err != nil {
+		return nil, err
+	}
+
+	factor, err := p.Factor(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	if unit == "" {
+		unit = p.BaseUnit
+	}
+
+	return &StockInUnit{
+		ProductId:    p.ID,
+		Unit:         unit,
+		CurrQuantity: float64(p.CurrQuantity) / float64(factor),
+		MinQuantity:  float64(p.MinQuantity) / float64(factor),
+	}, nil
+}
+
+// validateUnits checks the units have distinct names and positive factors, and that the
+// purchasing and dispensing units are among them
+func (p *Product) validateUnits() error {
+	names := map[string]bool{p.BaseUnit: true}
+	for i, u := range p.Units {
+		if u.Name == "" || names[u.Name] {
+			return errors.New("[ERROR] Units must have distinct names")
+		}
+
+		if u.Factor <= 0 {
+			return errors.New("[ERROR] Unit factor must be greater than 0")
+		}
+
+		names[u.Name] = true
+		p.Units[i].ID = 0
+		p.Units[i].ProductId = p.ID
+	}
+
+	if _, err := p.Factor(p.PurchaseUnit); err != nil {
+		return err
+	}
+
+	_, err := p.Factor(p.DispenseUnit)
+	return err
+}
+
+// loadUnits loads the units of the product, unless they are loaded already
+func (p *Product) loadUnits(db *gorm.DB) error {
+	if len(p.Units) != 0 {
+		return nil
+	}
+
+	units := []UnitOfMeasure{}
+	if err := db.Where(UnitOfMeasure{ProductId: p.ID}).Find(&units).Error; err != nil {
+		return err
+	}
+	p.Units = units
+	return nil
+}
+
+// unitFactor returns how many base units the unit of the product holds
+func unitFactor(db *gorm.DB, productId int, unit string) (int, error) {
+	p := Product{}
+	if err := db.Where(Product{ID: productId}).First(&p).Error; err != nil {
+		return 0, err
+	}
+
+	if err := p.loadUnits(db); err != nil {
+		return 0, err
+	}
+	return p.Factor(unit)
+}
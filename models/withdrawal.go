@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 	"time"
@@ -14,17 +15,54 @@ var (
 )
 
 type Withdrawal struct {
-	Query     map[string][]string `sql:"-" json:",omitempty"`
-	ID        int                 `json:"id"`
-	ProductId int                 `json:"product_id"`
-	Quantity  int                 `json:"quantity"`
-	IssuedAt  int                 `json:"issued_at"`
+	Query                map[string][]string `sql:"-" json:",omitempty"`
+	ID                   int                 `json:"id"`
+	ProductId            int                 `json:"product_id"`
+	Quantity             int                 `json:"quantity"`
+	IssuedAt             int                 `json:"issued_at"`
+	RequisitionId        int                 `json:"requisition_id"`
+	BackorderId          int                 `json:"backorder_id"`
+	KitId                int                 `json:"kit_id"`
+	ParentWithdrawalId   int                 `json:"parent_withdrawal_id"`
+	WorkOrderId          int                 `json:"work_order_id"`
+	SubstitutedProductId int                 `json:"substituted_product_id"`
+	Department           string              `json:"department" sql:"size:255"`
+	Requester            string              `json:"requester" sql:"size:255"`
+	PatientRef           string              `json:"patient_ref" sql:"size:255"`
+	Lots                 []WithdrawalLot     `json:"lots"`
+	Serials              []string            `json:"serials,omitempty" sql:"-"`
+}
+
+// WithdrawalLot is the quantity of a withdrawal taken from one lot, kept to trace recalled lots
+type WithdrawalLot struct {
+	ID           int    `json:"id"`
+	WithdrawalId int    `json:"withdrawal_id"`
+	LotId        int    `json:"lot_id"`
+	LotNumber    string `json:"lot_number" sql:"size:255"`
+	Quantity     int    `json:"quantity"`
 }
 
 func NewWithdrawl(prod Product, quantity int) *Withdrawal {
 	return &Withdrawal{ProductId: prod.ID, Quantity: quantity, IssuedAt: int(time.Now().Unix())}
 }
 
+// issuedTo records on the withdrawal who requested it and for which patient
+func (w *Withdrawal) issuedTo(recipient Withdrawal) {
+	w.Requester = recipient.Requester
+	w.Department = recipient.Department
+	w.PatientRef = recipient.PatientRef
+	w.Serials = recipient.Serials
+}
+
+// withdrawalLots returns the lots a withdrawal was taken from
+func withdrawalLots(lots []StockLot) []WithdrawalLot {
+	wls := []WithdrawalLot{}
+	for _, lot := range lots {
+		wls = append(wls, WithdrawalLot{LotId: lot.ID, LotNumber: lot.LotNumber, Quantity: lot.Quantity})
+	}
+	return wls
+}
+
 func (w *Withdrawal) Save(db *gorm.DB) error {
 	if err := db.Create(w).Error; err != nil {
 		return err
@@ -80,3 +118,80 @@ func isNumber(s string) bool {
 	}
 	return false
 }
+
+// BulkWithdrawal is a set of requests competing for the stock of a product, withdrawn at once.
+// When the stock doesn't cover every request it is shared by the allocation policy
+type BulkWithdrawal struct {
+	ProductId   int                 `json:"product_id"`
+	Requests    []AllocationRequest `json:"requests"`
+	Allocations []Allocation        `json:"allocations"`
+	Withdrawals []Withdrawal        `json:"withdrawals"`
+}
+
+// Save withdraws the quantity allocated to each request
+func (bw *BulkWithdrawal) Save(db *gorm.DB, policy AllocationPolicy) error {
+	if len(bw.Requests) == 0 {
+		return errors.New("[ERROR] Bulk withdrawal must have at least one request")
+	}
+
+	now := int(time.Now().Unix())
+	for i, req := range bw.Requests {
+		if req.Quantity <= 0 {
+			return errors.New("[ERROR] Requested quantity must be greater than 0")
+		}
+
+		bw.Requests[i].Source = AllocationWithdrawal
+		bw.Requests[i].SourceId = 0
+		if req.CreatedAt == 0 || req.CreatedAt > now {
+			bw.Requests[i].CreatedAt = now
+		}
+	}
+
+	p := Product{}
+	if err := db.Where(Product{ID: bw.ProductId}).First(&p).Error; err != nil {
+		return err
+	}
+
+	if err := p.loadAvailability(db); err != nil {
+		return err
+	}
+
+	witness, err := p.requiresWitness(db)
+	if err != nil {
+		return err
+	}
+
+	if witness {
+		return errWitnessRequired
+	}
+
+	if p.Serialized {
+		return errSerialsRequired
+	}
+
+	tx := db.Begin()
+	allocations, err := policy.allocate(tx, p.ID, p.CurrQuantity, bw.Requests)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	bw.Allocations = allocations
+	bw.Withdrawals = []Withdrawal{}
+	for _, a := range allocations {
+		if a.Allocated == 0 {
+			continue
+		}
+
+		w := NewWithdrawl(p, a.Allocated)
+		w.Requester = a.Request.Requester
+		w.Department = a.Request.Department
+		if err := withdrawStock(tx, w, Signoff{}); err != nil {
+			tx.Rollback()
+			return err
+		}
+		bw.Withdrawals = append(bw.Withdrawals, *w)
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	WorkOrderOpen     = "open"
+	WorkOrderPosted   = "posted"
+	WorkOrderCanceled = "canceled"
+)
+
+var errSerializedWorkOrder = errors.New("[ERROR] Serialized products can't be inputs or outputs of a work order")
+
+// WorkOrder transforms input products into output products, like assembling kits or splitting
+// bulk bottles into unit doses. Its movements are only applied to stock when it's posted
+type WorkOrder struct {
+	ID          int               `json:"id"`
+	Description string            `json:"description" sql:"size:255"`
+	Status      string            `json:"status" sql:"size:255"`
+	CreatedAt   int               `json:"created_at"`
+	PostedAt    int               `json:"posted_at"`
+	Inputs      []WorkOrderInput  `json:"inputs"`
+	Outputs     []WorkOrderOutput `json:"outputs"`
+}
+
+// WorkOrderInput is the quantity of a product consumed by the work order. When a lot is informed
+// the quantity is taken from it, otherwise from the lots expiring first
+type WorkOrderInput struct {
+	ID          int `json:"id"`
+	WorkOrderId int `json:"work_order_id"`
+	ProductId   int `json:"product_id"`
+	LotId       int `json:"lot_id"`
+	Quantity    int `json:"quantity"`
+}
+
+// WorkOrderOutput is the quantity of a product the work order is expected to produce and, once
+// posted, the quantity produced. Yield is the fraction of the expected quantity produced and
+// waste is the expected quantity that wasn't. When the inputs come from lots, each output gets
+// a lot of its own, numbered after the work order and the output, expiring with the earliest
+// input lot and linked to the input lots
+type WorkOrderOutput struct {
+	ID               int     `json:"id"`
+	WorkOrderId      int     `json:"work_order_id"`
+	ProductId        int     `json:"product_id"`
+	ExpectedQuantity int     `json:"expected_quantity"`
+	Quantity         int     `json:"quantity"`
+	Waste            int     `json:"waste"`
+	Yield            float64 `json:"yield"`
+	LotId            int     `json:"lot_id"`
+	LotNumber        string  `json:"lot_number" sql:"size:255"`
+	ExpiresAt        int     `json:"expires_at"`
+}
+
+// LotLink records that an input lot of a work order went into an output lot, so recalls and
+// traces of the input lot reach what was made from it
+type LotLink struct {
+	ID          int `json:"id"`
+	WorkOrderId int `json:"work_order_id"`
+	InputLotId  int `json:"input_lot_id"`
+	OutputLotId int `json:"output_lot_id"`
+}
+
+// Save new work order on database. Serialized products can't be inputs or outputs, as their units
+// aren't named
+func (wo *WorkOrder) Save(db *gorm.DB) error {
+	if len(wo.Inputs) == 0 || len(wo.Outputs) == 0 {
+		return errors.New("[ERROR] Work order must have at least one input and one output")
+	}
+
+	for i, in := range wo.Inputs {
+		if in.Quantity <= 0 {
+			return errors.New("[ERROR] Input quantity must be greater than 0")
+		}
+
+		p := Product{}
+		if err := db.Where(Product{ID: in.ProductId}).First(&p).Error; err != nil {
+			return err
+		}
+
+		if p.Serialized {
+			return errSerializedWorkOrder
+		}
+		wo.Inputs[i].ID = 0
+	}
+
+	for i, out := range wo.Outputs {
+		if out.ExpectedQuantity <= 0 {
+			return errors.New("[ERROR] Expected output quantity must be greater than 0")
+		}
+
+		p := Product{}
+		if err := db.Where(Product{ID: out.ProductId}).First(&p).Error; err != nil {
+			return err
+		}
+
+		if p.Serialized {
+			return errSerializedWorkOrder
+		}
+		wo.Outputs[i] = WorkOrderOutput{ProductId: out.ProductId, ExpectedQuantity: out.ExpectedQuantity}
+	}
+
+	wo.ID = 0
+	wo.Status = WorkOrderOpen
+	wo.CreatedAt = int(time.Now().Unix())
+	wo.PostedAt = 0
+
+	return db.Create(wo).Error
+}
+
+// Retreive work orders from database
+func (wo *WorkOrder) Retreive(db *gorm.DB) ([]WorkOrder, error) {
+	var wos []WorkOrder
+	if err := db.Where(*wo).Find(&wos).Error; err != nil {
+		return nil, err
+	}
+
+	for i, w := range wos {
+		inputs := []WorkOrderInput{}
+		if err := db.Model(w).Related(&inputs, "Inputs").Error; err != nil {
+			return nil, err
+		}
+		wos[i].Inputs = inputs
+
+		outputs := []WorkOrderOutput{}
+		if err := db.Model(w).Related(&outputs, "Outputs").Error; err != nil {
+			return nil, err
+		}
+		wos[i].Outputs = outputs
+	}
+
+	return wos, nil
+}
+
+// Post applies the work order to stock in one transaction: the inputs are withdrawn and the
+// outputs added. produced holds the quantity actually produced of each output, the outputs
+// not informed are considered produced as expected. Controlled inputs are withdrawn with the signoff
+func (wo *WorkOrder) Post(db *gorm.DB, produced []WorkOrderOutput, signoff Signoff) error {
+	if err := wo.retreiveWithStatus(db, WorkOrderOpen); err != nil {
+		return err
+	}
+
+	for _, p := range produced {
+		if wo.output(p.ID) == nil {
+			return errors.New("[ERROR] Output is not part of the work order")
+		}
+
+		if p.Quantity < 0 {
+			return errors.New("[ERROR] Produced quantity can't be negative")
+		}
+	}
+
+	// posting the work order first locks it, concurrent posts find it no longer open
+	tx := db.Begin()
+	wo.Status = WorkOrderPosted
+	wo.PostedAt = int(time.Now().Unix())
+	if err := wo.transition(tx, WorkOrderOpen); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	inputLots := []int{}
+	seen := map[int]bool{}
+	expiresAt := 0
+	for _, in := range wo.Inputs {
+		lots, err := removeStockFromLot(tx, in.ProductId, in.LotId, in.Quantity, signoff)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		for _, lot := range lots {
+			if !seen[lot.ID] {
+				seen[lot.ID] = true
+				inputLots = append(inputLots, lot.ID)
+			}
+
+			if lot.ExpiresAt != 0 && (expiresAt == 0 || lot.ExpiresAt < expiresAt) {
+				expiresAt = lot.ExpiresAt
+			}
+		}
+
+		w := &Withdrawal{ProductId: in.ProductId, Quantity: in.Quantity, IssuedAt: int(time.Now().Unix()), WorkOrderId: wo.ID}
+		w.Lots = withdrawalLots(lots)
+		if err := w.Save(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	for i := range wo.Outputs {
+		out := &wo.Outputs[i]
+		out.Quantity = out.ExpectedQuantity
+		for _, p := range produced {
+			if p.ID == out.ID {
+				out.Quantity = p.Quantity
+			}
+		}
+
+		out.Waste = out.ExpectedQuantity - out.Quantity
+		if out.Waste < 0 {
+			out.Waste = 0
+		}
+		out.Yield = float64(out.Quantity) / float64(out.ExpectedQuantity)
+
+		if out.Quantity > 0 {
+			if err := addStock(tx, out.ProductId, out.Quantity); err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			if len(inputLots) != 0 {
+				lotNumber := "WO" + strconv.Itoa(wo.ID) + "-" + strconv.Itoa(out.ID)
+				lot, err := addLotStock(tx, out.ProductId, lotNumber, expiresAt, out.Quantity)
+				if err != nil {
+					tx.Rollback()
+					return err
+				}
+				out.LotId = lot.ID
+				out.LotNumber = lot.LotNumber
+				out.ExpiresAt = lot.ExpiresAt
+
+				for _, inputLot := range inputLots {
+					link := LotLink{WorkOrderId: wo.ID, InputLotId: inputLot, OutputLotId: lot.ID}
+					if err := tx.Create(&link).Error; err != nil {
+						tx.Rollback()
+						return err
+					}
+				}
+			}
+		}
+
+		if err := tx.Save(out).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// Cancel an open work order
+func (wo *WorkOrder) Cancel(db *gorm.DB) error {
+	if err := wo.retreiveWithStatus(db, WorkOrderOpen); err != nil {
+		return err
+	}
+
+	wo.Status = WorkOrderCanceled
+	return wo.transition(db, WorkOrderOpen)
+}
+
+// transition saves the status of the work order, and its posting date, only if it still has the
+// status from. Otherwise someone else changed it meanwhile
+func (wo *WorkOrder) transition(db *gorm.DB, from string) error {
+	update := db.Model(wo).Where("status = ?", from).UpdateColumns(WorkOrder{Status: wo.Status, PostedAt: wo.PostedAt})
+	if update.Error != nil {
+		return update.Error
+	}
+
+	if update.RowsAffected != 1 {
+		return errors.New("[ERROR] Work order is no longer " + from)
+	}
+	return nil
+}
+
+// retreiveWithStatus loads the work order and checks it has the given status
+func (wo *WorkOrder) retreiveWithStatus(db *gorm.DB, status string) error {
+	wos, err := (&WorkOrder{ID: wo.ID}).Retreive(db)
+	if err != nil {
+		return err
+	}
+
+	if len(wos) != 1 {
+		return errors.New("record not found")
+	}
+
+	if wos[0].Status != status {
+		return errors.New("[ERROR] Work order can't be changed when its status is " + wos[0].Status)
+	}
+
+	*wo = wos[0]
+	return nil
+}
+
+func (wo *WorkOrder) output(id int) *WorkOrderOutput {
+	for i := range wo.Outputs {
+		if wo.Outputs[i].ID == id {
+			return &wo.Outputs[i]
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -71,18 +72,112 @@ func retreiveOpenOrder(w http.ResponseWriter, r *http.Request) errors.Http {
 	return nil
 }
 
+// approveOrder records the approval of the authenticated user, in its role. The body may carry a comment.
+// A draft order is submitted first, which approves it right away when no approver is required
 func approveOrder(w http.ResponseWriter, r *http.Request) errors.Http {
 	order := models.Order{}
+	decision := models.OrderApproval{}
 
 	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
 		return errors.BadRequest(err.Error())
 	}
 
-	if err := order.Approve(db); err != nil {
+	if err := BuildStructFromReqBody(&decision, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	approver, role, err := CallerFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+	decision.Approver = approver
+	decision.Role = role
+
+	orders, err := order.Retreive(db)
+	if err != nil {
 		return errors.InternalServerError(err.Error())
 	}
 
-	rend.JSON(w, http.StatusOK, order.ID)
+	if len(orders) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	if orders[0].SubmittedAt == 0 {
+		if err := publishOrderSubmission(&order); err != nil {
+			return errors.BadRequest(err.Error())
+		}
+
+		if order.Approved {
+			rend.JSON(w, http.StatusOK, order)
+			return nil
+		}
+	}
+
+	if err := order.Approve(db, approvalRules, &decision); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, order)
+	return nil
+}
+
+func submitOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := publishOrderSubmission(&order); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, order)
+	return nil
+}
+
+// rejectOrder closes the order on behalf of the authenticated user. The body must carry a comment
+func rejectOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	decision := models.OrderApproval{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&decision, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	approver, role, err := CallerFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+	decision.Approver = approver
+	decision.Role = role
+
+	if err := order.Reject(db, approvalRules, &decision); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, order)
+	return nil
+}
+
+func retreiveOrderApprovals(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	approval := models.OrderApproval{OrderId: order.ID}
+	approvals, err := approval.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, approvals)
 	return nil
 }
 
@@ -100,3 +195,93 @@ func cancelOrder(w http.ResponseWriter, r *http.Request) errors.Http {
 	rend.JSON(w, http.StatusOK, order.ID)
 	return nil
 }
+
+func updateOrderCostCenter(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := order.UpdateCostCenter(db, r.URL.Query().Get("cost_center")); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, order)
+	return nil
+}
+
+func addOrderLine(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	pp := models.PurchaseProduct{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&pp, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := order.AddLine(db, &pp); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, pp)
+	return nil
+}
+
+func updateOrderLine(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	pp := models.PurchaseProduct{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&pp, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := order.UpdateLine(db, &pp); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, pp)
+	return nil
+}
+
+func removeOrderLine(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	pp := models.PurchaseProduct{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&pp, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := order.RemoveLine(db, pp.ProductId); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, order.ID)
+	return nil
+}
+
+// publishOrderSubmission submits the order and notifies it through the 'order_submitted' topic
+func publishOrderSubmission(order *models.Order) error {
+	if err := order.Submit(db, approvalRules); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	producer.Publish("order_submitted", b)
+	return nil
+}
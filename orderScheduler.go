@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asvins/warehouse/models"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// orderScheduler submits the open order on a weekly cadence or when its value passes a threshold
+type orderScheduler struct {
+	weekday   time.Weekday
+	hour      int
+	minute    int
+	threshold float64
+	interval  time.Duration
+	lastRun   time.Time
+}
+
+func newOrderScheduler(cfg *Config) (*orderScheduler, error) {
+	weekday, ok := weekdays[strings.ToLower(cfg.Scheduler.Weekday)]
+	if !ok {
+		return nil, errors.New("[ERROR] Invalid scheduler weekday: '" + cfg.Scheduler.Weekday + "'")
+	}
+
+	at, err := time.Parse("15:04", cfg.Scheduler.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Scheduler.Interval <= 0 {
+		return nil, errors.New("[ERROR] Scheduler interval must be greater than 0")
+	}
+
+	return &orderScheduler{
+		weekday:   weekday,
+		hour:      at.Hour(),
+		minute:    at.Minute(),
+		threshold: cfg.Scheduler.Threshold,
+		interval:  time.Duration(cfg.Scheduler.Interval) * time.Second,
+		lastRun:   time.Now(),
+	}, nil
+}
+
+// Start checks the open order on every interval until the service stops
+func (s *orderScheduler) Start() {
+	go func() {
+		for now := range time.Tick(s.interval) {
+			s.run(now)
+		}
+	}()
+}
+
+func (s *orderScheduler) run(now time.Time) {
+	due := s.due(now)
+	if due {
+		s.lastRun = now
+	}
+
+	order, err := models.GetOpenOrder(db)
+	if err != nil {
+		if err.Error() != "record not found" {
+			fmt.Println("[ERROR] Scheduler unable to retreive open order: ", err.Error())
+		}
+		return
+	}
+
+	if !due && (s.threshold <= 0 || order.TotalValue() < s.threshold) {
+		return
+	}
+
+	fmt.Println("[INFO] Scheduler will submit open order", order.ID)
+	if err := publishOrderSubmission(order); err != nil {
+		fmt.Println("[ERROR] Scheduler unable to submit order: ", err.Error())
+	}
+}
+
+// due verifies if the scheduled time of this week was reached since the last run
+func (s *orderScheduler) due(now time.Time) bool {
+	if now.Weekday() != s.weekday {
+		return false
+	}
+
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), s.hour, s.minute, 0, 0, now.Location())
+	return !now.Before(scheduled) && s.lastRun.Before(scheduled)
+}
+
+// job is a task the service runs on its own interval, so a slow run of one doesn't delay the others
+type job struct {
+	name     string
+	interval time.Duration
+	run      func()
+}
+
+// newJobs returns the periodic jobs besides the order scheduler: notifying the purchases that passed
+// their promised delivery date and applying the cXML messages received from suppliers.
+// Jobs with an interval of 0 are disabled
+func newJobs(cfg *Config) ([]job, error) {
+	candidates := []struct {
+		name     string
+		interval int
+		run      func()
+	}{
+		{"late purchases", cfg.Scheduler.LateInterval, notifyLatePurchases},
+		{"edi inbound", cfg.Edi.InboundInterval, processEdiInbound},
+	}
+
+	jobs := []job{}
+	for _, c := range candidates {
+		if c.interval < 0 {
+			return nil, errors.New("[ERROR] Interval of job '" + c.name + "' can't be negative")
+		}
+
+		if c.interval > 0 {
+			jobs = append(jobs, job{name: c.name, interval: time.Duration(c.interval) * time.Second, run: c.run})
+		}
+	}
+	return jobs, nil
+}
+
+// start runs the job on every interval until the service stops
+func (j job) start() {
+	go func() {
+		for range time.Tick(j.interval) {
+			j.run()
+		}
+	}()
+}
+
+// notifyLatePurchases publishes a 'purchase_late' event once for every overdue purchase
+func notifyLatePurchases() {
+	purchase := models.Purchase{}
+	purchs, err := purchase.RetreiveLate(db)
+	if err != nil {
+		fmt.Println("[ERROR] Unable to retreive late purchases: ", err.Error())
+		return
+	}
+
+	for _, p := range purchs {
+		if p.LateNotifiedAt != 0 {
+			continue
+		}
+
+		b, err := json.Marshal(p)
+		if err != nil {
+			fmt.Println("[ERROR] Unable to marshal late purchase: ", err.Error())
+			continue
+		}
+		producer.Publish("purchase_late", b)
+
+		if err := p.NotifyLate(db); err != nil {
+			fmt.Println("[ERROR] Unable to mark purchase as notified: ", err.Error())
+		}
+	}
+}
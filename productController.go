@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/asvins/router/errors"
 	"github.com/asvins/warehouse/models"
@@ -83,6 +84,24 @@ func updateProduct(w http.ResponseWriter, r *http.Request) errors.Http {
 		return errors.BadRequest(err.Error())
 	}
 
+	curr := models.Product{ID: p.ID}
+	ps, err := curr.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(ps) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	if ps[0].Controlled != p.Controlled {
+		return errors.BadRequest("[ERROR] A product can't become controlled or stop being controlled once registered")
+	}
+
+	if ps[0].Controlled && ps[0].CurrQuantity != p.CurrQuantity {
+		return errors.BadRequest("[ERROR] The stock of controlled products is changed through witnessed adjustments and counts")
+	}
+
 	if err := p.Update(db); err != nil {
 		return errors.InternalServerError(err.Error())
 	}
@@ -98,7 +117,7 @@ func deleteProduct(w http.ResponseWriter, r *http.Request) errors.Http {
 	}
 
 	if err := p.Delete(db); err != nil {
-		return errors.InternalServerError(err.Error())
+		return errors.BadRequest(err.Error())
 	}
 
 	rend.JSON(w, http.StatusOK, p)
@@ -118,11 +137,39 @@ func consumeProduct(w http.ResponseWriter, r *http.Request) errors.Http {
 		return errors.BadRequest(err.Error())
 	}
 
-	if err := p.Consume(db, qt); err != nil {
-		return errors.InternalServerError(err.Error())
+	qt, err = p.ToBase(db, qt, params.Get("unit"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
 	}
 
-	ps, err := p.Retreive(db)
+	consumed := models.Product{ID: p.ID}
+	if params.Get("substitute") == "true" {
+		s, err := p.ConsumeOrSubstitute(db, qt, BuildRecipientFromUrlValues(params))
+		if err != nil {
+			return errors.InternalServerError(err.Error())
+		}
+		consumed.ID = s.ID
+	} else if params.Get("backorder") == "true" {
+		backorder, err := BuildBackorderFromUrlValues(params)
+		if err != nil {
+			return errors.BadRequest(err.Error())
+		}
+
+		if err := p.ConsumeOrBackorder(db, qt, backorder); err != nil {
+			return errors.InternalServerError(err.Error())
+		}
+	} else {
+		signoff, err := BuildSignoffFromRequest(r)
+		if err != nil {
+			return errors.BadRequest(err.Error())
+		}
+
+		if err := p.ConsumeWitnessed(db, qt, BuildRecipientFromUrlValues(params), signoff); err != nil {
+			return errors.InternalServerError(err.Error() + substitutesHint(p, qt))
+		}
+	}
+
+	ps, err := consumed.Retreive(db)
 	if err != nil {
 		return errors.InternalServerError(err.Error())
 	}
@@ -134,3 +181,81 @@ func consumeProduct(w http.ResponseWriter, r *http.Request) errors.Http {
 	rend.JSON(w, http.StatusOK, ps[0])
 	return nil
 }
+
+// BuildRecipientFromUrlValues reads the requester, department, patient reference and the comma
+// separated serial numbers of a consumption
+func BuildRecipientFromUrlValues(params url.Values) models.Withdrawal {
+	recipient := models.Withdrawal{Requester: params.Get("requester"), Department: params.Get("department"), PatientRef: params.Get("patient")}
+	if params.Get("serials") != "" {
+		recipient.Serials = strings.Split(params.Get("serials"), ",")
+	}
+	return recipient
+}
+
+// retreiveProductStock returns the stock of the product in the unit informed, or in its base unit
+func retreiveProductStock(w http.ResponseWriter, r *http.Request) errors.Http {
+	p := models.Product{}
+	params := r.URL.Query()
+	if err := FillProductIdWithUrlValue(&p, params); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	ps, err := p.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(ps) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	stock, err := ps[0].StockIn(db, params.Get("unit"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, stock)
+	return nil
+}
+
+// retreiveProductAvailability tells if the product has the quantity, in its dispensing unit unless
+// another is informed, suggesting substitutes when it doesn't
+func retreiveProductAvailability(w http.ResponseWriter, r *http.Request) errors.Http {
+	p := models.Product{}
+	params := r.URL.Query()
+	if err := FillProductIdWithUrlValue(&p, params); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	qt, err := strconv.Atoi(params.Get("quantity"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	qt, err = p.ToBase(db, qt, params.Get("unit"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	a, err := p.Availability(db, qt)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, a)
+	return nil
+}
+
+// substitutesHint lists the substitutes with the quantity in stock, suggested when the product doesn't have it
+func substitutesHint(p models.Product, quantity int) string {
+	substitutes, err := p.Substitutes(db, quantity)
+	if err != nil || len(substitutes) == 0 {
+		return ""
+	}
+
+	names := []string{}
+	for _, s := range substitutes {
+		names = append(names, s.Name+" (id "+strconv.Itoa(s.ID)+")")
+	}
+	return ". Substitutes in stock: " + strings.Join(names, ", ")
+}
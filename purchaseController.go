@@ -122,6 +122,18 @@ func retreiveConcludedPurchases(w http.ResponseWriter, r *http.Request) errors.H
 	return nil
 }
 
+func retreiveLatePurchases(w http.ResponseWriter, r *http.Request) errors.Http {
+	purchase := models.Purchase{}
+	purchs, err := purchase.RetreiveLate(db)
+
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, purchs)
+	return nil
+}
+
 func confirmPurchase(w http.ResponseWriter, r *http.Request) errors.Http {
 	var purchase models.Purchase
 
@@ -129,8 +141,19 @@ func confirmPurchase(w http.ResponseWriter, r *http.Request) errors.Http {
 		return errors.BadRequest(err.Error())
 	}
 
-	if err := purchase.Confirm(db); err != nil {
-		return errors.InternalServerError(err.Error())
+	expectedAt := 0
+	if param := r.URL.Query().Get("expected_at"); param != "" {
+		var err error
+		if expectedAt, err = strconv.Atoi(param); err != nil {
+			return errors.BadRequest(err.Error())
+		}
+	}
+
+	if err := purchase.Confirm(db, expectedAt); err != nil {
+		if err.Error() == "record not found" {
+			return errors.NotFound(err.Error())
+		}
+		return errors.BadRequest(err.Error())
 	}
 
 	rend.JSON(w, http.StatusOK, purchase.ID)
@@ -151,3 +174,45 @@ func concludePurchase(w http.ResponseWriter, r *http.Request) errors.Http {
 	rend.JSON(w, http.StatusOK, purchase.ID)
 	return nil
 }
+
+func receivePurchase(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+	receipt := models.Receipt{}
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&receipt, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	receipt.PurchaseId = purchase.ID
+	if err := receipt.Save(db, allocationPolicy, receivingPolicy); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	for _, b := range receipt.Backorders {
+		publishBackorderFulfillment(&b)
+	}
+
+	rend.JSON(w, http.StatusOK, receipt)
+	return nil
+}
+
+func retreivePurchaseReceipts(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	receipt := models.Receipt{PurchaseId: purchase.ID}
+	receipts, err := receipt.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, receipts)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillRecallIdWithUrlValue(rc *models.Recall, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	rc.ID = id
+
+	return nil
+}
+
+func retreiveRecall(w http.ResponseWriter, r *http.Request) errors.Http {
+	rc := models.Recall{}
+	if err := BuildStructFromQueryString(&rc, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rcs, err := rc.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(rcs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, rcs)
+	return nil
+}
+
+func retreiveRecallById(w http.ResponseWriter, r *http.Request) errors.Http {
+	rc := models.Recall{}
+
+	if err := FillRecallIdWithUrlValue(&rc, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rcs, err := rc.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(rcs) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, rcs[0])
+	return nil
+}
+
+// insertRecall recalls the lots and publishes the trace of their withdrawals
+func insertRecall(w http.ResponseWriter, r *http.Request) errors.Http {
+	rc := models.Recall{}
+	if err := BuildStructFromReqBody(&rc, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := rc.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	trace, err := rc.Trace(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if err := publishRecall(trace); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, trace)
+	return nil
+}
+
+// retreiveRecallTrace lists every withdrawal of the recalled lots
+func retreiveRecallTrace(w http.ResponseWriter, r *http.Request) errors.Http {
+	rc := models.Recall{}
+
+	if err := FillRecallIdWithUrlValue(&rc, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	trace, err := rc.Trace(db)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return errors.NotFound(err.Error())
+		}
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, trace)
+	return nil
+}
+
+// publishRecall notifies that lots were recalled, with the withdrawals to follow up
+func publishRecall(trace *models.RecallTrace) error {
+	msg, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+
+	producer.Publish("product_recalled", msg)
+	return nil
+}
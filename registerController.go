@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+	"github.com/asvins/warehouse/token"
+)
+
+// witnessToken is a short lived token a witness hands to the operator of a controlled movement
+type witnessToken struct {
+	Token     string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// BuildSignoffFromRequest reads the operator and the witness of a movement. The operator is the
+// authenticated caller; the witness is the user the token in the X-Witness-Token header was issued
+// to, who can't be the caller. Movements of products that aren't controlled don't need either
+func BuildSignoffFromRequest(r *http.Request) (models.Signoff, error) {
+	signoff := models.Signoff{}
+	if operator, _, err := CallerFromRequest(r); err == nil {
+		signoff.Operator = operator
+	}
+
+	t := r.Header.Get("X-Witness-Token")
+	if t == "" {
+		return signoff, nil
+	}
+
+	witness, err := token.VerifySubject(ServerConfig.Register.Secret, t)
+	if err != nil {
+		return signoff, err
+	}
+
+	if witness == signoff.Operator {
+		return signoff, fmt.Errorf("[ERROR] The witness must be someone other than the operator")
+	}
+	signoff.Witness = witness
+	return signoff, nil
+}
+
+// issueWitnessToken gives the authenticated caller a token vouching for them as witness
+func issueWitnessToken(w http.ResponseWriter, r *http.Request) errors.Http {
+	witness, _, err := CallerFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	expiresAt := time.Now().Unix() + int64(ServerConfig.Register.WitnessTTL)
+	rend.JSON(w, http.StatusOK, witnessToken{Token: token.SignSubject(ServerConfig.Register.Secret, witness, expiresAt), ExpiresAt: expiresAt})
+	return nil
+}
+
+// adjustProduct corrects the stock of the product by the quantity, negative to decrease it
+func adjustProduct(w http.ResponseWriter, r *http.Request) errors.Http {
+	p := models.Product{}
+	params := r.URL.Query()
+	if err := FillProductIdWithUrlValue(&p, params); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	signoff, err := BuildSignoffFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	qt, err := strconv.Atoi(params.Get("quantity"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := p.Adjust(db, qt, params.Get("reason"), signoff); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, p)
+	return nil
+}
+
+// countProduct sets the stock of the product to the quantity counted
+func countProduct(w http.ResponseWriter, r *http.Request) errors.Http {
+	p := models.Product{}
+	params := r.URL.Query()
+	if err := FillProductIdWithUrlValue(&p, params); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	signoff, err := BuildSignoffFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	qt, err := strconv.Atoi(params.Get("quantity"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := p.Count(db, qt, signoff); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, p)
+	return nil
+}
+
+func retreiveRegister(w http.ResponseWriter, r *http.Request) errors.Http {
+	re := models.RegisterEntry{}
+	if err := BuildStructFromQueryString(&re, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	entries, err := re.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(entries) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, entries)
+	return nil
+}
+
+// verifyRegister checks the register chain, reporting altered and deleted entries
+func verifyRegister(w http.ResponseWriter, r *http.Request) errors.Http {
+	v, err := models.VerifyRegister(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, v)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+// FillPeriodWithUrlValues reads the 'from' and 'to' timestamps of a report.
+// By default the period goes from the beginning up to now
+func FillPeriodWithUrlValues(params url.Values) (int, int, error) {
+	from, to := 0, int(time.Now().Unix())
+
+	if param := params.Get("from"); param != "" {
+		var err error
+		if from, err = strconv.Atoi(param); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if param := params.Get("to"); param != "" {
+		var err error
+		if to, err = strconv.Atoi(param); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return from, to, nil
+}
+
+func retreiveSupplierReport(w http.ResponseWriter, r *http.Request) errors.Http {
+	from, to, err := FillPeriodWithUrlValues(r.URL.Query())
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	scorecards, err := models.SupplierScorecards(db, from, to)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, scorecards)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillRequisitionIdWithUrlValue(req *models.Requisition, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	req.ID = id
+
+	return nil
+}
+
+func retreiveRequisition(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+	if err := BuildStructFromQueryString(&req, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	reqs, err := req.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(reqs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, reqs)
+	return nil
+}
+
+func retreiveRequisitionById(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+
+	if err := FillRequisitionIdWithUrlValue(&req, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	reqs, err := req.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(reqs) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, reqs[0])
+	return nil
+}
+
+func insertRequisition(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+	if err := BuildStructFromReqBody(&req, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := req.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, req)
+	return nil
+}
+
+// approveRequisition expects, optionally, the lines with an adjusted approved_quantity.
+// The approver is the authenticated caller
+func approveRequisition(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+	decision := models.Requisition{}
+
+	if err := FillRequisitionIdWithUrlValue(&req, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&decision, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	approver, _, err := CallerFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := req.Approve(db, approver, decision.Lines); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, req)
+	return nil
+}
+
+// rejectRequisition expects the comment. The approver is the authenticated caller
+func rejectRequisition(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+	decision := models.Requisition{}
+
+	if err := FillRequisitionIdWithUrlValue(&req, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&decision, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	approver, _, err := CallerFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := req.Reject(db, approver, decision.Comment); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, req)
+	return nil
+}
+
+func pickRequisition(w http.ResponseWriter, r *http.Request) errors.Http {
+	req := models.Requisition{}
+
+	params := r.URL.Query()
+	if err := FillRequisitionIdWithUrlValue(&req, params); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	signoff, err := BuildSignoffFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if _, err := req.Pick(db, allocationPolicy, signoff); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, req)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+type rfqRequest struct {
+	SupplierIds []int `json:"supplier_ids"`
+}
+
+type quotesRequest struct {
+	Quotes []models.Quote `json:"quotes"`
+}
+
+type awardsRequest struct {
+	Awards []models.Award `json:"awards"`
+}
+
+func FillRfqIdWithUrlValue(rfq *models.Rfq, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	rfq.ID = id
+
+	return nil
+}
+
+func sendOrderRfqs(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	req := rfqRequest{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&req, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rfqs, err := models.NewRfqsFromOrder(db, &order, req.SupplierIds)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	for _, rfq := range rfqs {
+		b, err := json.Marshal(rfq)
+		if err != nil {
+			return errors.InternalServerError(err.Error())
+		}
+		producer.Publish("rfq_sent", b)
+	}
+
+	rend.JSON(w, http.StatusOK, rfqs)
+	return nil
+}
+
+func retreiveRfqById(w http.ResponseWriter, r *http.Request) errors.Http {
+	rfq := models.Rfq{}
+
+	if err := FillRfqIdWithUrlValue(&rfq, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rfqs, err := rfq.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(rfqs) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, rfqs[0])
+	return nil
+}
+
+func submitRfqQuotes(w http.ResponseWriter, r *http.Request) errors.Http {
+	rfq := models.Rfq{}
+	req := quotesRequest{}
+
+	if err := FillRfqIdWithUrlValue(&rfq, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&req, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := rfq.SubmitQuotes(db, req.Quotes); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, rfq.ID)
+	return nil
+}
+
+func compareOrderQuotes(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	comparison, err := models.CompareQuotes(db, &order)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, comparison)
+	return nil
+}
+
+func awardOrderQuotes(w http.ResponseWriter, r *http.Request) errors.Http {
+	order := models.Order{}
+	req := awardsRequest{}
+
+	if err := FillOrderIdWithUrlValue(&order, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&req, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	orders, err := order.Award(db, approvalRules, req.Awards)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	for _, o := range orders {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return errors.InternalServerError(err.Error())
+		}
+		producer.Publish("order_submitted", b)
+	}
+
+	rend.JSON(w, http.StatusOK, orders)
+	return nil
+}
@@ -21,14 +21,39 @@ func DefRoutes() *router.Router {
 		discoveryMap["insert_product"] = map[string]string{"POST": "/api/inventory/product"}
 		discoveryMap["update_product"] = map[string]string{"PUT": "/api/inventory/product/:id"}
 		discoveryMap["delete_product"] = map[string]string{"DELETE": "/api/inventory/product/:id"}
-		discoveryMap["consume_product"] = map[string]string{"GET": "/api/inventory/product/:id/consume/:quantity"}
+		discoveryMap["consume_product"] = map[string]string{"GET": "/api/inventory/product/:id/consume/:quantity?unit=:unit&requester=:requester&department=:department&patient=:patient&serials=:serials&substitute=:bool&backorder=:bool&priority=:priority"}
+		discoveryMap["retreive_product_stock"] = map[string]string{"GET": "/api/inventory/product/:id/stock?unit=:unit"}
+		discoveryMap["retreive_product_availability"] = map[string]string{"GET": "/api/inventory/product/:id/availability?quantity=:quantity&unit=:unit"}
+		discoveryMap["adjust_product"] = map[string]string{"PUT": "/api/inventory/product/:id/adjust/:quantity?reason=:reason"}
+		discoveryMap["count_product"] = map[string]string{"PUT": "/api/inventory/product/:id/count/:quantity"}
 
 		// order
 		discoveryMap["retreive_order"] = map[string]string{"GET": "/api/inventory/order"}
 		discoveryMap["retreive_open_order"] = map[string]string{"GET": "/api/inventory/order/open"}
 		discoveryMap["retreive_order_by_id"] = map[string]string{"GET": "/api/inventory/order/:id"}
+		discoveryMap["submit_order"] = map[string]string{"PUT": "/api/inventory/order/:id/submit"}
 		discoveryMap["approve_order"] = map[string]string{"PUT": "/api/inventory/order/:id/approve"}
+		discoveryMap["reject_order"] = map[string]string{"PUT": "/api/inventory/order/:id/reject"}
+		discoveryMap["retreive_order_approvals"] = map[string]string{"GET": "/api/inventory/order/:id/approvals"}
 		discoveryMap["cancel_order"] = map[string]string{"PUT": "/api/inventory/order/:id/cancel"}
+		discoveryMap["update_order_cost_center"] = map[string]string{"PUT": "/api/inventory/order/:id/costCenter/:cost_center"}
+		discoveryMap["add_order_line"] = map[string]string{"POST": "/api/inventory/order/:id/lines"}
+		discoveryMap["update_order_line"] = map[string]string{"PUT": "/api/inventory/order/:id/lines"}
+		discoveryMap["remove_order_line"] = map[string]string{"DELETE": "/api/inventory/order/:id/lines"}
+		discoveryMap["send_order_rfq"] = map[string]string{"POST": "/api/inventory/order/:id/rfq"}
+		discoveryMap["compare_order_quotes"] = map[string]string{"GET": "/api/inventory/order/:id/quotes"}
+		discoveryMap["award_order_quotes"] = map[string]string{"PUT": "/api/inventory/order/:id/award"}
+
+		// rfq
+		discoveryMap["retreive_rfq_by_id"] = map[string]string{"GET": "/api/inventory/rfq/:id"}
+		discoveryMap["submit_rfq_quotes"] = map[string]string{"POST": "/api/inventory/rfq/:id/quotes"}
+
+		// supplier
+		discoveryMap["retreive_supplier"] = map[string]string{"GET": "/api/inventory/supplier"}
+		discoveryMap["retreive_supplier_by_id"] = map[string]string{"GET": "/api/inventory/supplier/:id"}
+		discoveryMap["insert_supplier"] = map[string]string{"POST": "/api/inventory/supplier"}
+		discoveryMap["update_supplier"] = map[string]string{"PUT": "/api/inventory/supplier/:id"}
+		discoveryMap["delete_supplier"] = map[string]string{"DELETE": "/api/inventory/supplier/:id"}
 
 		// purchase
 		discoveryMap["retreive_purchase"] = map[string]string{"GET": "/api/inventory/purchase"}
@@ -37,8 +62,35 @@ func DefRoutes() *router.Router {
 		discoveryMap["confirm_purchase"] = map[string]string{"GET": "/api/inventory/purchase/query/open"}
 		discoveryMap["confirm_purchase"] = map[string]string{"GET": "/api/inventory/purchase/query/confirmed"}
 		discoveryMap["confirm_purchase"] = map[string]string{"GET": "/api/inventory/purchase//queryconcluded"}
-		discoveryMap["confirm_purchase"] = map[string]string{"PUT": "/api/inventory/purchase/:id/confirm"}
+		discoveryMap["retreive_late_purchases"] = map[string]string{"GET": "/api/inventory/purchase/late"}
+		discoveryMap["confirm_purchase"] = map[string]string{"PUT": "/api/inventory/purchase/:id/confirm?expected_at=:timestamp"}
 		discoveryMap["conclude_purchase"] = map[string]string{"PUT": "/api/inventory/purchase/:id/conclude"}
+		discoveryMap["receive_purchase"] = map[string]string{"POST": "/api/inventory/purchase/:id/receipt"}
+		discoveryMap["retreive_purchase_receipts"] = map[string]string{"GET": "/api/inventory/purchase/:id/receipt"}
+		discoveryMap["insert_purchase_invoice"] = map[string]string{"POST": "/api/inventory/purchase/:id/invoice"}
+		discoveryMap["insert_purchase_return"] = map[string]string{"POST": "/api/inventory/purchase/:id/return"}
+		discoveryMap["retreive_purchase_document"] = map[string]string{"GET": "/api/inventory/purchase/:id/document?format=pdf|csv"}
+		discoveryMap["send_purchase_order_request"] = map[string]string{"POST": "/api/inventory/purchase/:id/edi"}
+		discoveryMap["retreive_purchase_link"] = map[string]string{"GET": "/api/inventory/purchase/:id/link"}
+		discoveryMap["retreive_purchase_changes"] = map[string]string{"GET": "/api/inventory/purchase/:id/changes"}
+		discoveryMap["accept_purchase_change"] = map[string]string{"PUT": "/api/inventory/purchase/:id/changes/:change_id/accept"}
+		discoveryMap["reject_purchase_change"] = map[string]string{"PUT": "/api/inventory/purchase/:id/changes/:change_id/reject"}
+
+		// supplier portal
+		discoveryMap["retreive_supplier_purchase"] = map[string]string{"GET": "/api/supplier/purchase/:token"}
+		discoveryMap["supplier_confirm_purchase"] = map[string]string{"PUT": "/api/supplier/purchase/:token/confirm"}
+		discoveryMap["supplier_reject_purchase"] = map[string]string{"PUT": "/api/supplier/purchase/:token/reject"}
+
+		// invoice
+		discoveryMap["retreive_invoice"] = map[string]string{"GET": "/api/inventory/invoice"}
+		discoveryMap["retreive_invoice_by_id"] = map[string]string{"GET": "/api/inventory/invoice/:id"}
+		discoveryMap["match_invoice"] = map[string]string{"PUT": "/api/inventory/invoice/:id/match"}
+
+		// supplier return
+		discoveryMap["retreive_supplier_return"] = map[string]string{"GET": "/api/inventory/return"}
+		discoveryMap["retreive_supplier_return_by_id"] = map[string]string{"GET": "/api/inventory/return/:id"}
+		discoveryMap["ship_supplier_return"] = map[string]string{"PUT": "/api/inventory/return/:id/ship"}
+		discoveryMap["credit_supplier_return"] = map[string]string{"POST": "/api/inventory/return/:id/credit"}
 
 		// purchase products
 		discoveryMap["retreive_purchase_product"] = map[string]string{"GET": "/api/inventory/purchaseProduct"}
@@ -49,6 +101,69 @@ func DefRoutes() *router.Router {
 
 		// withdrawal
 		discoveryMap["retreive_withdrawl"] = map[string]string{"GET": "/api/inventory/withdrawal"}
+		discoveryMap["insert_bulk_withdrawal"] = map[string]string{"POST": "/api/inventory/product/:id/withdrawals"}
+		discoveryMap["retreive_allocation_log"] = map[string]string{"GET": "/api/inventory/allocation"}
+
+		// backorder
+		discoveryMap["retreive_backorder"] = map[string]string{"GET": "/api/inventory/backorder"}
+		discoveryMap["cancel_backorder"] = map[string]string{"PUT": "/api/inventory/backorder/:id/cancel"}
+
+		// substitution
+		discoveryMap["retreive_substitution_group"] = map[string]string{"GET": "/api/inventory/substitution"}
+		discoveryMap["insert_substitution_group"] = map[string]string{"POST": "/api/inventory/substitution"}
+		discoveryMap["update_substitution_group"] = map[string]string{"PUT": "/api/inventory/substitution/:id"}
+		discoveryMap["delete_substitution_group"] = map[string]string{"DELETE": "/api/inventory/substitution/:id"}
+
+		// work order
+		discoveryMap["retreive_work_order"] = map[string]string{"GET": "/api/inventory/workOrder"}
+		discoveryMap["retreive_work_order_by_id"] = map[string]string{"GET": "/api/inventory/workOrder/:id"}
+		discoveryMap["insert_work_order"] = map[string]string{"POST": "/api/inventory/workOrder"}
+		discoveryMap["post_work_order"] = map[string]string{"PUT": "/api/inventory/workOrder/:id/post"}
+		discoveryMap["cancel_work_order"] = map[string]string{"PUT": "/api/inventory/workOrder/:id/cancel"}
+
+		// lot
+		discoveryMap["retreive_lot"] = map[string]string{"GET": "/api/inventory/lot"}
+		discoveryMap["move_lot"] = map[string]string{"PUT": "/api/inventory/lot/:id/location/:location"}
+
+		// inspection
+		discoveryMap["retreive_inspection"] = map[string]string{"GET": "/api/inventory/inspection"}
+		discoveryMap["retreive_inspection_by_id"] = map[string]string{"GET": "/api/inventory/inspection/:id"}
+		discoveryMap["insert_inspection"] = map[string]string{"POST": "/api/inventory/inspection"}
+		discoveryMap["inspect_inspection"] = map[string]string{"PUT": "/api/inventory/inspection/:id/inspect"}
+
+		// serial
+		discoveryMap["retreive_serial_unit"] = map[string]string{"GET": "/api/inventory/serial/:serial_number?product_id=:product_id"}
+		discoveryMap["return_serial_unit"] = map[string]string{"PUT": "/api/inventory/serial/:serial_number/return?product_id=:product_id&requester=:requester&department=:department&patient=:patient&reason=:reason"}
+
+		// recall
+		discoveryMap["retreive_recall"] = map[string]string{"GET": "/api/inventory/recall"}
+		discoveryMap["retreive_recall_by_id"] = map[string]string{"GET": "/api/inventory/recall/:id"}
+		discoveryMap["insert_recall"] = map[string]string{"POST": "/api/inventory/recall"}
+		discoveryMap["retreive_recall_trace"] = map[string]string{"GET": "/api/inventory/recall/:id/trace"}
+
+		// controlled register
+		discoveryMap["retreive_register"] = map[string]string{"GET": "/api/inventory/register"}
+		discoveryMap["verify_register"] = map[string]string{"GET": "/api/inventory/register/verify"}
+		discoveryMap["issue_witness_token"] = map[string]string{"POST": "/api/inventory/register/witness"}
+
+		// requisition
+		discoveryMap["retreive_requisition"] = map[string]string{"GET": "/api/inventory/requisition"}
+		discoveryMap["retreive_requisition_by_id"] = map[string]string{"GET": "/api/inventory/requisition/:id"}
+		discoveryMap["insert_requisition"] = map[string]string{"POST": "/api/inventory/requisition"}
+		discoveryMap["approve_requisition"] = map[string]string{"PUT": "/api/inventory/requisition/:id/approve"}
+		discoveryMap["reject_requisition"] = map[string]string{"PUT": "/api/inventory/requisition/:id/reject"}
+		discoveryMap["pick_requisition"] = map[string]string{"PUT": "/api/inventory/requisition/:id/pick"}
+
+		// budget
+		discoveryMap["retreive_budget"] = map[string]string{"GET": "/api/inventory/budget"}
+		discoveryMap["insert_budget"] = map[string]string{"POST": "/api/inventory/budget"}
+		discoveryMap["update_budget"] = map[string]string{"PUT": "/api/inventory/budget/:id"}
+		discoveryMap["delete_budget"] = map[string]string{"DELETE": "/api/inventory/budget/:id"}
+
+		// reports
+		discoveryMap["retreive_supplier_report"] = map[string]string{"GET": "/api/inventory/reports/suppliers?from=:timestamp&to=:timestamp"}
+		discoveryMap["retreive_budget_report"] = map[string]string{"GET": "/api/inventory/reports/budgets?period=:yyyy-mm"}
+		discoveryMap["retreive_excursion_report"] = map[string]string{"GET": "/api/inventory/reports/excursions?from=:timestamp&to=:timestamp&location=:location"}
 
 		rend.JSON(w, http.StatusOK, discoveryMap)
 		return nil
@@ -61,16 +176,42 @@ func DefRoutes() *router.Router {
 	r.Handle("/api/inventory/product/:id", router.PUT, updateProduct, []router.Interceptor{})
 	r.Handle("/api/inventory/product/:id", router.DELETE, deleteProduct, []router.Interceptor{})
 	r.Handle("/api/inventory/product/:id/consume/:quantity", router.GET, consumeProduct, []router.Interceptor{})
+	r.Handle("/api/inventory/product/:id/stock", router.GET, retreiveProductStock, []router.Interceptor{})
+	r.Handle("/api/inventory/product/:id/availability", router.GET, retreiveProductAvailability, []router.Interceptor{})
+	r.Handle("/api/inventory/product/:id/adjust/:quantity", router.PUT, adjustProduct, []router.Interceptor{})
+	r.Handle("/api/inventory/product/:id/count/:quantity", router.PUT, countProduct, []router.Interceptor{})
 
 	// order routes
 	r.Handle("/api/inventory/order", router.GET, retreiveOrder, []router.Interceptor{})
 	r.Handle("/api/inventory/order/open", router.GET, retreiveOpenOrder, []router.Interceptor{})
 	r.Handle("/api/inventory/order/:id", router.GET, retreiveOrderById, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/submit", router.PUT, submitOrder, []router.Interceptor{})
 	r.Handle("/api/inventory/order/:id/approve", router.PUT, approveOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/reject", router.PUT, rejectOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/approvals", router.GET, retreiveOrderApprovals, []router.Interceptor{})
 	r.Handle("/api/inventory/order/:id/cancel", router.PUT, cancelOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/costCenter/:cost_center", router.PUT, updateOrderCostCenter, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/lines", router.POST, addOrderLine, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/lines", router.PUT, updateOrderLine, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/lines", router.DELETE, removeOrderLine, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/rfq", router.POST, sendOrderRfqs, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/quotes", router.GET, compareOrderQuotes, []router.Interceptor{})
+	r.Handle("/api/inventory/order/:id/award", router.PUT, awardOrderQuotes, []router.Interceptor{})
+
+	// rfq
+	r.Handle("/api/inventory/rfq/:id", router.GET, retreiveRfqById, []router.Interceptor{})
+	r.Handle("/api/inventory/rfq/:id/quotes", router.POST, submitRfqQuotes, []router.Interceptor{})
+
+	// supplier
+	r.Handle("/api/inventory/supplier", router.GET, retreiveSupplier, []router.Interceptor{})
+	r.Handle("/api/inventory/supplier/:id", router.GET, retreiveSupplierById, []router.Interceptor{})
+	r.Handle("/api/inventory/supplier", router.POST, insertSupplier, []router.Interceptor{})
+	r.Handle("/api/inventory/supplier/:id", router.PUT, updateSupplier, []router.Interceptor{})
+	r.Handle("/api/inventory/supplier/:id", router.DELETE, deleteSupplier, []router.Interceptor{})
 
 	// purchase
 	r.Handle("/api/inventory/purchase", router.GET, retreivePurchase, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/late", router.GET, retreiveLatePurchases, []router.Interceptor{})
 	r.Handle("/api/inventory/purchase/:id", router.GET, retreivePurchaseById, []router.Interceptor{})
 	r.Handle("/api/inventory/purchase/order/:order_id", router.GET, retreivePurchaseByOrderId, []router.Interceptor{})
 	r.Handle("/api/inventory/purchase/query/open", router.GET, retreiveOpenPurchase, []router.Interceptor{})
@@ -78,6 +219,32 @@ func DefRoutes() *router.Router {
 	r.Handle("/api/inventory/purchase/query/concluded", router.GET, retreiveConcludedPurchases, []router.Interceptor{})
 	r.Handle("/api/inventory/purchase/:id/confirm", router.PUT, confirmPurchase, []router.Interceptor{})
 	r.Handle("/api/inventory/purchase/:id/conclude", router.PUT, concludePurchase, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/receipt", router.POST, receivePurchase, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/receipt", router.GET, retreivePurchaseReceipts, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/invoice", router.POST, insertPurchaseInvoice, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/return", router.POST, insertPurchaseReturn, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/document", router.GET, retreivePurchaseDocument, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/edi", router.POST, sendPurchaseOrderRequest, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/link", router.GET, retreivePurchaseLink, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/changes", router.GET, retreivePurchaseChanges, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/changes/:change_id/accept", router.PUT, acceptPurchaseChange, []router.Interceptor{})
+	r.Handle("/api/inventory/purchase/:id/changes/:change_id/reject", router.PUT, rejectPurchaseChange, []router.Interceptor{})
+
+	// supplier portal
+	r.Handle("/api/supplier/purchase/:token", router.GET, retreiveSupplierPurchase, []router.Interceptor{})
+	r.Handle("/api/supplier/purchase/:token/confirm", router.PUT, supplierConfirmPurchase, []router.Interceptor{})
+	r.Handle("/api/supplier/purchase/:token/reject", router.PUT, supplierRejectPurchase, []router.Interceptor{})
+
+	// invoice
+	r.Handle("/api/inventory/invoice", router.GET, retreiveInvoice, []router.Interceptor{})
+	r.Handle("/api/inventory/invoice/:id", router.GET, retreiveInvoiceById, []router.Interceptor{})
+	r.Handle("/api/inventory/invoice/:id/match", router.PUT, matchInvoice, []router.Interceptor{})
+
+	// supplier return
+	r.Handle("/api/inventory/return", router.GET, retreiveSupplierReturn, []router.Interceptor{})
+	r.Handle("/api/inventory/return/:id", router.GET, retreiveSupplierReturnById, []router.Interceptor{})
+	r.Handle("/api/inventory/return/:id/ship", router.PUT, shipSupplierReturn, []router.Interceptor{})
+	r.Handle("/api/inventory/return/:id/credit", router.POST, creditSupplierReturn, []router.Interceptor{})
 
 	// purchase products
 	r.Handle("/api/inventory/purchaseProduct", router.GET, retreivePurchaseProducts, []router.Interceptor{})
@@ -88,6 +255,69 @@ func DefRoutes() *router.Router {
 
 	// withdrawal
 	r.Handle("/api/inventory/withdrawal", router.GET, retreiveWithdrawal, []router.Interceptor{})
+	r.Handle("/api/inventory/product/:id/withdrawals", router.POST, insertBulkWithdrawal, []router.Interceptor{})
+	r.Handle("/api/inventory/allocation", router.GET, retreiveAllocationLog, []router.Interceptor{})
+
+	// backorder
+	r.Handle("/api/inventory/backorder", router.GET, retreiveBackorder, []router.Interceptor{})
+	r.Handle("/api/inventory/backorder/:id/cancel", router.PUT, cancelBackorder, []router.Interceptor{})
+
+	// substitution
+	r.Handle("/api/inventory/substitution", router.GET, retreiveSubstitutionGroup, []router.Interceptor{})
+	r.Handle("/api/inventory/substitution", router.POST, insertSubstitutionGroup, []router.Interceptor{})
+	r.Handle("/api/inventory/substitution/:id", router.PUT, updateSubstitutionGroup, []router.Interceptor{})
+	r.Handle("/api/inventory/substitution/:id", router.DELETE, deleteSubstitutionGroup, []router.Interceptor{})
+
+	// work order
+	r.Handle("/api/inventory/workOrder", router.GET, retreiveWorkOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/workOrder/:id", router.GET, retreiveWorkOrderById, []router.Interceptor{})
+	r.Handle("/api/inventory/workOrder", router.POST, insertWorkOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/workOrder/:id/post", router.PUT, postWorkOrder, []router.Interceptor{})
+	r.Handle("/api/inventory/workOrder/:id/cancel", router.PUT, cancelWorkOrder, []router.Interceptor{})
+
+	// lot
+	r.Handle("/api/inventory/lot", router.GET, retreiveStockLot, []router.Interceptor{})
+	r.Handle("/api/inventory/lot/:id/location/:location", router.PUT, moveStockLot, []router.Interceptor{})
+
+	// inspection
+	r.Handle("/api/inventory/inspection", router.GET, retreiveInspection, []router.Interceptor{})
+	r.Handle("/api/inventory/inspection/:id", router.GET, retreiveInspectionById, []router.Interceptor{})
+	r.Handle("/api/inventory/inspection", router.POST, insertInspection, []router.Interceptor{})
+	r.Handle("/api/inventory/inspection/:id/inspect", router.PUT, inspectInspection, []router.Interceptor{})
+
+	// serial
+	r.Handle("/api/inventory/serial/:serial_number", router.GET, retreiveSerialUnit, []router.Interceptor{})
+	r.Handle("/api/inventory/serial/:serial_number/return", router.PUT, returnSerialUnit, []router.Interceptor{})
+
+	// recall
+	r.Handle("/api/inventory/recall", router.GET, retreiveRecall, []router.Interceptor{})
+	r.Handle("/api/inventory/recall/:id", router.GET, retreiveRecallById, []router.Interceptor{})
+	r.Handle("/api/inventory/recall", router.POST, insertRecall, []router.Interceptor{})
+	r.Handle("/api/inventory/recall/:id/trace", router.GET, retreiveRecallTrace, []router.Interceptor{})
+
+	// controlled register
+	r.Handle("/api/inventory/register", router.GET, retreiveRegister, []router.Interceptor{})
+	r.Handle("/api/inventory/register/verify", router.GET, verifyRegister, []router.Interceptor{})
+	r.Handle("/api/inventory/register/witness", router.POST, issueWitnessToken, []router.Interceptor{})
+
+	// requisition
+	r.Handle("/api/inventory/requisition", router.GET, retreiveRequisition, []router.Interceptor{})
+	r.Handle("/api/inventory/requisition/:id", router.GET, retreiveRequisitionById, []router.Interceptor{})
+	r.Handle("/api/inventory/requisition", router.POST, insertRequisition, []router.Interceptor{})
+	r.Handle("/api/inventory/requisition/:id/approve", router.PUT, approveRequisition, []router.Interceptor{})
+	r.Handle("/api/inventory/requisition/:id/reject", router.PUT, rejectRequisition, []router.Interceptor{})
+	r.Handle("/api/inventory/requisition/:id/pick", router.PUT, pickRequisition, []router.Interceptor{})
+
+	// budget
+	r.Handle("/api/inventory/budget", router.GET, retreiveBudget, []router.Interceptor{})
+	r.Handle("/api/inventory/budget", router.POST, insertBudget, []router.Interceptor{})
+	r.Handle("/api/inventory/budget/:id", router.PUT, updateBudget, []router.Interceptor{})
+	r.Handle("/api/inventory/budget/:id", router.DELETE, deleteBudget, []router.Interceptor{})
+
+	// reports
+	r.Handle("/api/inventory/reports/suppliers", router.GET, retreiveSupplierReport, []router.Interceptor{})
+	r.Handle("/api/inventory/reports/budgets", router.GET, retreiveBudgetReport, []router.Interceptor{})
+	r.Handle("/api/inventory/reports/excursions", router.GET, retreiveExcursionReport, []router.Interceptor{})
 
 	// interceptors
 	r.AddBaseInterceptor("/", logger.NewLogger())
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+// BuildSerialUnitFromUrlValues reads the serial number and, optionally, the product of a unit
+func BuildSerialUnitFromUrlValues(params url.Values) (*models.SerialUnit, error) {
+	su := &models.SerialUnit{SerialNumber: params.Get("serial_number")}
+
+	if params.Get("product_id") != "" {
+		productId, err := strconv.Atoi(params.Get("product_id"))
+		if err != nil {
+			return nil, err
+		}
+		su.ProductId = productId
+	}
+
+	return su, nil
+}
+
+// retreiveSerialUnit shows the full history of the units with the serial number
+func retreiveSerialUnit(w http.ResponseWriter, r *http.Request) errors.Http {
+	su, err := BuildSerialUnitFromUrlValues(r.URL.Query())
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	sus, err := su.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(sus) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, sus)
+	return nil
+}
+
+// returnSerialUnit puts a withdrawn unit back in stock, recording who returned it and why
+func returnSerialUnit(w http.ResponseWriter, r *http.Request) errors.Http {
+	params := r.URL.Query()
+	su, err := BuildSerialUnitFromUrlValues(params)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	returned := models.SerialEvent{
+		Requester:  params.Get("requester"),
+		Department: params.Get("department"),
+		PatientRef: params.Get("patient"),
+		Reason:     params.Get("reason"),
+	}
+
+	if err := su.Return(db, returned); err != nil {
+		if err.Error() == "record not found" {
+			return errors.NotFound(err.Error())
+		}
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, su)
+	return nil
+}
@@ -8,16 +8,20 @@ import (
 	"github.com/asvins/common_db/postgres"
 	"github.com/asvins/common_io"
 	"github.com/asvins/utils/config"
+	"github.com/asvins/warehouse/models"
 	"github.com/jinzhu/gorm"
 	"github.com/unrolled/render"
 )
 
 var (
-	ServerConfig *Config        = new(Config)
-	rend         *render.Render = render.New() // used to write into responses
-	db           *gorm.DB
-	producer     *common_io.Producer
-	consumer     *common_io.Consumer
+	ServerConfig     *Config        = new(Config)
+	rend             *render.Render = render.New() // used to write into responses
+	db               *gorm.DB
+	approvalRules    models.ApprovalRules
+	allocationPolicy models.AllocationPolicy
+	receivingPolicy  models.ReceivingPolicy
+	producer         *common_io.Producer
+	consumer         *common_io.Consumer
 )
 
 // function that will run before main
@@ -27,6 +31,23 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	ServerConfig.LoadSecrets()
+
+	approvalRules, err = ServerConfig.ApprovalRules()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	allocationPolicy, err = ServerConfig.AllocationPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	receivingPolicy, err = ServerConfig.ReceivingPolicy()
+	if err != nil {
+		log.Fatal(err)
+	}
+	models.RegisterSecret = ServerConfig.Register.Secret
 
 	DatabaseConfig := postgres.NewConfig(ServerConfig.Database.User, ServerConfig.Database.DbName, ServerConfig.Database.SSLMode)
 	db = postgres.GetDatabase(DatabaseConfig)
@@ -39,8 +60,31 @@ func init() {
 }
 
 func main() {
+	if err := ServerConfig.ValidateSupplierPortal(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := ServerConfig.ValidateRegister(); err != nil {
+		log.Fatal(err)
+	}
+
 	router := DefRoutes()
 
+	scheduler, err := newOrderScheduler(ServerConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	scheduler.Start()
+
+	jobs, err := newJobs(ServerConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, j := range jobs {
+		j.start()
+	}
+
 	fmt.Println("[INFO] Server running on port:", ServerConfig.Server.Port)
 	http.ListenAndServe(":"+ServerConfig.Server.Port, router)
 }
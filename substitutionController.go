@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillSubstitutionGroupIdWithUrlValue(sg *models.SubstitutionGroup, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	sg.ID = id
+
+	return nil
+}
+
+func retreiveSubstitutionGroup(w http.ResponseWriter, r *http.Request) errors.Http {
+	sg := models.SubstitutionGroup{}
+	if err := BuildStructFromQueryString(&sg, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	sgs, err := sg.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(sgs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, sgs)
+	return nil
+}
+
+func insertSubstitutionGroup(w http.ResponseWriter, r *http.Request) errors.Http {
+	sg := models.SubstitutionGroup{}
+	if err := BuildStructFromReqBody(&sg, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sg.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sg)
+	return nil
+}
+
+func updateSubstitutionGroup(w http.ResponseWriter, r *http.Request) errors.Http {
+	sg := models.SubstitutionGroup{}
+
+	if err := BuildStructFromReqBody(&sg, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := FillSubstitutionGroupIdWithUrlValue(&sg, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sg.Update(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sg)
+	return nil
+}
+
+func deleteSubstitutionGroup(w http.ResponseWriter, r *http.Request) errors.Http {
+	sg := models.SubstitutionGroup{}
+	if err := FillSubstitutionGroupIdWithUrlValue(&sg, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sg.Delete(db); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sg)
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillSupplierIdWithUrlValue(s *models.Supplier, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	s.ID = id
+
+	return nil
+}
+
+func retreiveSupplier(w http.ResponseWriter, r *http.Request) errors.Http {
+	s := models.Supplier{}
+	if err := BuildStructFromQueryString(&s, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	suppliers, err := s.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(suppliers) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, suppliers)
+	return nil
+}
+
+func retreiveSupplierById(w http.ResponseWriter, r *http.Request) errors.Http {
+	s := models.Supplier{}
+
+	if err := FillSupplierIdWithUrlValue(&s, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	suppliers, err := s.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(suppliers) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, suppliers[0])
+	return nil
+}
+
+func insertSupplier(w http.ResponseWriter, r *http.Request) errors.Http {
+	s := models.Supplier{}
+	if err := BuildStructFromReqBody(&s, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := s.Save(db); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, s)
+	return nil
+}
+
+func updateSupplier(w http.ResponseWriter, r *http.Request) errors.Http {
+	s := models.Supplier{}
+
+	if err := BuildStructFromReqBody(&s, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := FillSupplierIdWithUrlValue(&s, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := s.Update(db); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, s)
+	return nil
+}
+
+func deleteSupplier(w http.ResponseWriter, r *http.Request) errors.Http {
+	s := models.Supplier{}
+	if err := FillSupplierIdWithUrlValue(&s, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := s.Delete(db); err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, s)
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+	"github.com/asvins/warehouse/token"
+)
+
+type purchaseLink struct {
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// supplierPurchase is what a supplier sees of a purchase: what was ordered and its
+// delivery, but nothing about how it's charged internally
+type supplierPurchase struct {
+	ID          int                    `json:"id"`
+	CreatedAt   int                    `json:"created_at"`
+	ConfirmedAt int                    `json:"confirmed_at"`
+	ConcludedAt int                    `json:"concluded_at"`
+	ExpectedAt  int                    `json:"expected_at"`
+	RejectedAt  int                    `json:"rejected_at"`
+	TotalValue  float64                `json:"total_value"`
+	Lines       []supplierPurchaseLine `json:"lines"`
+}
+
+type supplierPurchaseLine struct {
+	PurchaseProductId  int     `json:"purchase_product_id"`
+	ProductId          int     `json:"product_id"`
+	Quantity           int     `json:"quantity"`
+	Unit               string  `json:"unit"`
+	Value              float64 `json:"value"`
+	DespatchedQuantity int     `json:"despatched_quantity"`
+	ReceivedQuantity   int     `json:"received_quantity"`
+}
+
+func newSupplierPurchase(p models.Purchase) supplierPurchase {
+	sp := supplierPurchase{
+		ID:          p.ID,
+		CreatedAt:   p.CreatedAt,
+		ConfirmedAt: p.ConfirmedAt,
+		ConcludedAt: p.ConcludedAt,
+		ExpectedAt:  p.ExpectedAt,
+		RejectedAt:  p.RejectedAt,
+		TotalValue:  p.TotalValue,
+		Lines:       []supplierPurchaseLine{},
+	}
+
+	for _, pp := range p.PurschaseOrder.Pproducts {
+		sp.Lines = append(sp.Lines, supplierPurchaseLine{
+			PurchaseProductId:  pp.ID,
+			ProductId:          pp.ProductId,
+			Quantity:           pp.Quantity,
+			Unit:               pp.Unit,
+			Value:              pp.Value,
+			DespatchedQuantity: pp.DespatchedQuantity,
+			ReceivedQuantity:   pp.ReceivedQuantity,
+		})
+	}
+	return sp
+}
+
+// FillPurchaseIdWithUrlToken verifies the supplier's token and fills the purchase it grants access to
+func FillPurchaseIdWithUrlToken(p *models.Purchase, params url.Values) error {
+	id, err := token.Verify(ServerConfig.SupplierPortal.Secret, params.Get("token"))
+	if err != nil {
+		return err
+	}
+	p.ID = id
+
+	return nil
+}
+
+func FillPurchaseChangeIdWithUrlValue(pc *models.PurchaseChange, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("change_id"))
+	if err != nil {
+		return err
+	}
+	pc.ID = id
+
+	return nil
+}
+
+func retreivePurchaseLink(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	purchases, err := purchase.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(purchases) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	expiresAt := time.Now().Unix() + int64(ServerConfig.SupplierPortal.TokenTTL)
+	t := token.Sign(ServerConfig.SupplierPortal.Secret, purchase.ID, expiresAt)
+
+	rend.JSON(w, http.StatusOK, purchaseLink{Token: t, URL: ServerConfig.SupplierPortal.BaseURL + t, ExpiresAt: expiresAt})
+	return nil
+}
+
+func retreiveSupplierPurchase(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlToken(&purchase, r.URL.Query()); err != nil {
+		return errors.NotFound(err.Error())
+	}
+
+	purchases, err := purchase.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(purchases) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, newSupplierPurchase(purchases[0]))
+	return nil
+}
+
+func supplierConfirmPurchase(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+	response := models.SupplierResponse{}
+
+	if err := FillPurchaseIdWithUrlToken(&purchase, r.URL.Query()); err != nil {
+		return errors.NotFound(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&response, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := purchase.SupplierConfirm(db, &response); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, purchase.ID)
+	return nil
+}
+
+func supplierRejectPurchase(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+	response := models.SupplierResponse{}
+
+	if err := FillPurchaseIdWithUrlToken(&purchase, r.URL.Query()); err != nil {
+		return errors.NotFound(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&response, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := purchase.SupplierReject(db, &response); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, purchase.ID)
+	return nil
+}
+
+func retreivePurchaseChanges(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	pc := models.PurchaseChange{PurchaseId: purchase.ID}
+	changes, err := pc.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, changes)
+	return nil
+}
+
+func acceptPurchaseChange(w http.ResponseWriter, r *http.Request) errors.Http {
+	pc := models.PurchaseChange{}
+
+	if err := FillPurchaseChangeIdWithUrlValue(&pc, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := pc.Accept(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, pc)
+	return nil
+}
+
+func rejectPurchaseChange(w http.ResponseWriter, r *http.Request) errors.Http {
+	pc := models.PurchaseChange{}
+
+	if err := FillPurchaseChangeIdWithUrlValue(&pc, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := pc.Reject(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, pc)
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillSupplierReturnIdWithUrlValue(sr *models.SupplierReturn, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	sr.ID = id
+
+	return nil
+}
+
+func retreiveSupplierReturn(w http.ResponseWriter, r *http.Request) errors.Http {
+	sr := models.SupplierReturn{}
+	if err := BuildStructFromQueryString(&sr, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	srs, err := sr.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(srs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, srs)
+	return nil
+}
+
+func retreiveSupplierReturnById(w http.ResponseWriter, r *http.Request) errors.Http {
+	sr := models.SupplierReturn{}
+
+	if err := FillSupplierReturnIdWithUrlValue(&sr, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	srs, err := sr.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(srs) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, srs[0])
+	return nil
+}
+
+func insertPurchaseReturn(w http.ResponseWriter, r *http.Request) errors.Http {
+	var purchase models.Purchase
+	sr := models.SupplierReturn{}
+
+	if err := FillPurchaseIdWithUrlValue(&purchase, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&sr, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	signoff, err := BuildSignoffFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	sr.PurchaseId = purchase.ID
+	if err := sr.Save(db, signoff); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sr)
+	return nil
+}
+
+func shipSupplierReturn(w http.ResponseWriter, r *http.Request) errors.Http {
+	sr := models.SupplierReturn{}
+
+	if err := FillSupplierReturnIdWithUrlValue(&sr, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sr.Ship(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sr)
+	return nil
+}
+
+func creditSupplierReturn(w http.ResponseWriter, r *http.Request) errors.Http {
+	sr := models.SupplierReturn{}
+	note := models.CreditNote{}
+
+	if err := FillSupplierReturnIdWithUrlValue(&sr, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := BuildStructFromReqBody(&note, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := sr.Credit(db, &note); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, sr)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+// retreiveExcursionReport lists the temperature excursions of the period, at the location when one is informed
+func retreiveExcursionReport(w http.ResponseWriter, r *http.Request) errors.Http {
+	params := r.URL.Query()
+	from, to, err := FillPeriodWithUrlValues(params)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	excursions, err := models.ExcursionReport(db, from, to, params.Get("location"))
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, excursions)
+	return nil
+}
+
+// publishTemperatureExcursion notifies that a lot was put on hold after a temperature excursion
+func publishTemperatureExcursion(excursion *models.TemperatureExcursion) error {
+	msg, err := json.Marshal(excursion)
+	if err != nil {
+		return err
+	}
+
+	producer.Publish("temperature_excursion", msg)
+	return nil
+}
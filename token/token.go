@@ -0,0 +1,68 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sign returns a token granting access to the resource with the given id until expiresAt.
+// The token has the format <id>.<expiresAt>.<signature>
+func Sign(secret string, id int, expiresAt int64) string {
+	return SignSubject(secret, strconv.Itoa(id), expiresAt)
+}
+
+// Verify checks the signature and the expiration of the token and returns the id it grants access to
+func Verify(secret string, token string) (int, error) {
+	subject, err := VerifySubject(secret, token)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(subject)
+}
+
+// SignSubject returns a token vouching for the subject, like the login of a user, until expiresAt.
+// The token has the format <subject>.<expiresAt>.<signature>
+func SignSubject(secret string, subject string, expiresAt int64) string {
+	payload := subject + "." + strconv.FormatInt(expiresAt, 10)
+	return payload + "." + signature(secret, payload)
+}
+
+// VerifySubject checks the signature and the expiration of the token and returns the subject it vouches for
+func VerifySubject(secret string, token string) (string, error) {
+	sep := strings.LastIndex(token, ".")
+	if sep == -1 {
+		return "", errors.New("[ERROR] Malformed token")
+	}
+
+	payload := token[:sep]
+	expSep := strings.LastIndex(payload, ".")
+	if expSep == -1 {
+		return "", errors.New("[ERROR] Malformed token")
+	}
+
+	if !hmac.Equal([]byte(token[sep+1:]), []byte(signature(secret, payload))) {
+		return "", errors.New("[ERROR] Invalid token signature")
+	}
+
+	expiresAt, err := strconv.ParseInt(payload[expSep+1:], 10, 64)
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().Unix() > expiresAt {
+		return "", errors.New("[ERROR] Token expired")
+	}
+
+	return payload[:expSep], nil
+}
+
+func signature(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
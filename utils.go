@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"io"
+	"net/http"
 	"net/url"
 
 	"github.com/asvins/warehouse/decoder"
@@ -16,3 +18,14 @@ func BuildStructFromReqBody(dst interface{}, body io.ReadCloser) error {
 	decoder := decoder.NewDecoder()
 	return decoder.DecodeReqBody(dst, body)
 }
+
+// CallerFromRequest returns the login and the role of the authenticated user making the request.
+// Users are authenticated by the gateway, which forwards them in the X-User and X-Role headers
+func CallerFromRequest(r *http.Request) (string, string, error) {
+	user := r.Header.Get("X-User")
+	role := r.Header.Get("X-Role")
+	if user == "" || role == "" {
+		return "", "", errors.New("[ERROR] Request is not authenticated")
+	}
+	return user, role, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/asvins/router/errors"
 	"github.com/asvins/warehouse/models"
@@ -28,3 +29,43 @@ func retreiveWithdrawal(w http.ResponseWriter, r *http.Request) errors.Http {
 
 	return nil
 }
+
+// insertBulkWithdrawal serves competing requests for a product at once, following the allocation policy
+func insertBulkWithdrawal(w http.ResponseWriter, r *http.Request) errors.Http {
+	bw := models.BulkWithdrawal{}
+	if err := BuildStructFromReqBody(&bw, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	bw.ProductId = id
+	if err := bw.Save(db, allocationPolicy); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, bw)
+	return nil
+}
+
+func retreiveAllocationLog(w http.ResponseWriter, r *http.Request) errors.Http {
+	al := models.AllocationLog{}
+	if err := BuildStructFromQueryString(&al, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	logs, err := al.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(logs) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, logs)
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/asvins/router/errors"
+	"github.com/asvins/warehouse/models"
+)
+
+func FillWorkOrderIdWithUrlValue(wo *models.WorkOrder, params url.Values) error {
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return err
+	}
+	wo.ID = id
+
+	return nil
+}
+
+func retreiveWorkOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	wo := models.WorkOrder{}
+	if err := BuildStructFromQueryString(&wo, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	wos, err := wo.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(wos) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, wos)
+	return nil
+}
+
+func retreiveWorkOrderById(w http.ResponseWriter, r *http.Request) errors.Http {
+	wo := models.WorkOrder{}
+
+	if err := FillWorkOrderIdWithUrlValue(&wo, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	wos, err := wo.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(wos) != 1 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, wos[0])
+	return nil
+}
+
+func insertWorkOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	wo := models.WorkOrder{}
+	if err := BuildStructFromReqBody(&wo, r.Body); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := wo.Save(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, wo)
+	return nil
+}
+
+// postWorkOrder expects, optionally, the outputs with the quantity actually produced
+func postWorkOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	wo := models.WorkOrder{}
+	produced := models.WorkOrder{}
+
+	if err := FillWorkOrderIdWithUrlValue(&wo, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if r.ContentLength != 0 {
+		if err := BuildStructFromReqBody(&produced, r.Body); err != nil {
+			return errors.BadRequest(err.Error())
+		}
+	}
+
+	signoff, err := BuildSignoffFromRequest(r)
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := wo.Post(db, produced.Outputs, signoff); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, wo)
+	return nil
+}
+
+func cancelWorkOrder(w http.ResponseWriter, r *http.Request) errors.Http {
+	wo := models.WorkOrder{}
+
+	if err := FillWorkOrderIdWithUrlValue(&wo, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	if err := wo.Cancel(db); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, wo)
+	return nil
+}
+
+func retreiveStockLot(w http.ResponseWriter, r *http.Request) errors.Http {
+	lot := models.StockLot{}
+	if err := BuildStructFromQueryString(&lot, r.URL.Query()); err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	lots, err := lot.Retreive(db)
+	if err != nil {
+		return errors.InternalServerError(err.Error())
+	}
+
+	if len(lots) == 0 {
+		return errors.NotFound("record not found")
+	}
+
+	rend.JSON(w, http.StatusOK, lots)
+	return nil
+}
+
+// moveStockLot stores the lot at another location
+func moveStockLot(w http.ResponseWriter, r *http.Request) errors.Http {
+	params := r.URL.Query()
+	id, err := strconv.Atoi(params.Get("id"))
+	if err != nil {
+		return errors.BadRequest(err.Error())
+	}
+
+	lot := models.StockLot{ID: id}
+	if err := lot.Move(db, params.Get("location")); err != nil {
+		if err.Error() == "record not found" {
+			return errors.NotFound(err.Error())
+		}
+		return errors.BadRequest(err.Error())
+	}
+
+	rend.JSON(w, http.StatusOK, lot)
+	return nil
+}